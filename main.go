@@ -1,23 +1,212 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"project01/src/auth"
 	"project01/src/config"
 	"project01/src/db"
+	"project01/src/media"
+	"project01/src/migrate"
+	"project01/src/repositories"
+	"project01/src/retention"
 	"project01/src/router"
+	"project01/src/storage"
+	"project01/src/timeline"
+	"strconv"
 )
 
+const usage = `Usage:
+  project01 [-auto-migrate]              start the server
+  project01 migrate up                   apply pending migrations
+  project01 migrate down [steps]         roll back the last migration, or the last steps migrations
+  project01 migrate status               list migrations and whether they are applied
+  project01 migrate create <name>        add an empty migration to ` + migrate.Dir + `
+  project01 seed [file]                  load development data, sql/inserts.sql by default
+  project01 timeline rebuild <user-id>   rebuild the home timeline of a user
+  project01 counters repair              recompute like, reply, follower and post counts
+  project01 media gc                     delete unused uploads and files nothing points at
+  project01 posts purge                  erase posts deleted longer ago than POST_RETENTION
+`
+
 func main() {
+	autoMigrate := flag.Bool("auto-migrate", false, "apply pending migrations before starting the server")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+
 	config.Load()
 
+	args := flag.Args()
+	if len(args) > 0 {
+		if err := runCommand(args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := auth.LoadKeys(); err != nil {
+		log.Fatal(err)
+	}
+
 	db, err := db.New()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
+	if *autoMigrate {
+		applied, err := migrate.Up(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, migration := range applied {
+			log.Printf("applied migration %d_%s", migration.Version, migration.Name)
+		}
+	}
+
+	timeline.Start(db)
+	media.StartCleanup(db, storage.New())
+	retention.Start(db)
+
 	r := router.New(db)
 
 	http.ListenAndServe(":8080", r)
 }
+
+func runCommand(args []string) error {
+	switch {
+	case args[0] == "migrate" && len(args) == 3 && args[1] == "create":
+		up, down, err := migrate.Create(migrate.Dir, args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return nil
+
+	case args[0] == "migrate" && len(args) >= 2:
+		db, err := db.New()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		switch args[1] {
+		case "up":
+			applied, err := migrate.Up(db)
+			for _, migration := range applied {
+				fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+			}
+			if err == nil && len(applied) == 0 {
+				fmt.Println("no pending migrations")
+			}
+			return err
+
+		case "down":
+			steps := 1
+			if len(args) > 2 {
+				if steps, err = strconv.Atoi(args[2]); err != nil || steps < 1 {
+					return fmt.Errorf("invalid number of steps %q", args[2])
+				}
+			}
+
+			rolledBack, err := migrate.Down(db, steps)
+			for _, migration := range rolledBack {
+				fmt.Printf("rolled back %d_%s\n", migration.Version, migration.Name)
+			}
+			return err
+
+		case "status":
+			statuses, err := migrate.CurrentStatus(db)
+			if err != nil {
+				return err
+			}
+			for _, status := range statuses {
+				appliedAt := "pending"
+				if status.AppliedAt != nil {
+					appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, appliedAt)
+			}
+			return nil
+		}
+
+	case args[0] == "timeline" && len(args) == 3 && args[1] == "rebuild":
+		userID, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid user id %q", args[2])
+		}
+
+		db, err := db.New()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		return timeline.Rebuild(db, userID)
+
+	case args[0] == "counters" && len(args) == 2 && args[1] == "repair":
+		db, err := db.New()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		postsFixed, usersFixed, err := repositories.NewCounterRepository(db).Repair()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("repaired %d posts and %d users\n", postsFixed, usersFixed)
+		return nil
+
+	case args[0] == "media" && len(args) == 2 && args[1] == "gc":
+		db, err := db.New()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		deleted, err := media.CollectGarbage(context.Background(), db, storage.New())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deleted %d files\n", deleted)
+		return nil
+
+	case args[0] == "posts" && len(args) == 2 && args[1] == "purge":
+		db, err := db.New()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		purged, err := retention.Purge(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("purged %d posts\n", purged)
+		return nil
+
+	case args[0] == "seed" && len(args) <= 2:
+		path := "sql/inserts.sql"
+		if len(args) == 2 {
+			path = args[1]
+		}
+
+		db, err := db.New()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		return migrate.Seed(db, path)
+	}
+
+	flag.Usage()
+	os.Exit(2)
+	return nil
+}
@@ -1,61 +0,0 @@
-DROP TABLE IF EXISTS notifications;
-DROP TABLE IF EXISTS likes;
-DROP TABLE IF EXISTS posts;
-DROP TABLE IF EXISTS followers;
-DROP TABLE IF EXISTS users;
-
-CREATE TABLE users (
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(100) NOT NULL,
-    email VARCHAR(100) NOT NULL UNIQUE,
-    username VARCHAR(100) NOT NULL UNIQUE,
-    avatar_url VARCHAR(200),
-    bio TEXT,
-    birthdate DATE NOT NULL,
-    password VARCHAR(100) NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE followers (
-    id SERIAL PRIMARY KEY,
-    user_id INT NOT NULL,
-    follower_id INT NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-    FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
-    UNIQUE (user_id, follower_id)
-);
-
-CREATE TABLE posts (
-    id SERIAL PRIMARY KEY,
-    parent_id INT,
-    author_id INT NOT NULL,
-    content TEXT NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE,
-    FOREIGN KEY (parent_id) REFERENCES posts(id) ON DELETE CASCADE
-);
-
-CREATE TABLE likes (
-    id SERIAL PRIMARY KEY,
-    post_id INT NOT NULL,
-    user_id INT NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
-    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-    UNIQUE (post_id, user_id)
-);
-
-CREATE TABLE notifications (
-    id SERIAL PRIMARY KEY,
-    user_id INTEGER,
-    type TEXT,
-    source_user_id INTEGER,
-    source_post_id INTEGER NULL,
-    is_read BOOLEAN DEFAULT FALSE,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-    FOREIGN KEY (source_user_id) REFERENCES users(id) ON DELETE CASCADE,
-    FOREIGN KEY (source_post_id) REFERENCES posts(id) ON DELETE SET NULL
-);
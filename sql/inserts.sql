@@ -1,12 +1,12 @@
-INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password) VALUES ('Alice', 'alice@mail.com', 'alice', 'https://i.pravatar.cc/150?img=1', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW');
+INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password, email_verified_at) VALUES ('Alice', 'alice@mail.com', 'alice', 'https://i.pravatar.cc/150?img=1', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW', CURRENT_TIMESTAMP);
 
-INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password) VALUES ('Bob', 'bob@mail.com', 'bob', 'https://i.pravatar.cc/150?img=2', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW');
+INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password, email_verified_at) VALUES ('Bob', 'bob@mail.com', 'bob', 'https://i.pravatar.cc/150?img=2', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW', CURRENT_TIMESTAMP);
 
-INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password) VALUES ('Charlie', 'charlie@mail.com', 'charlie', 'https://i.pravatar.cc/150?img=3', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW');
+INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password, email_verified_at) VALUES ('Charlie', 'charlie@mail.com', 'charlie', 'https://i.pravatar.cc/150?img=3', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW', CURRENT_TIMESTAMP);
 
-INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password) VALUES ('David', 'david@mail.com', 'david', 'https://i.pravatar.cc/150?img=4', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW');
+INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password, email_verified_at) VALUES ('David', 'david@mail.com', 'david', 'https://i.pravatar.cc/150?img=4', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW', CURRENT_TIMESTAMP);
 
-INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password) VALUES ('Eve', 'eve@mail.com', 'eve', 'https://i.pravatar.cc/150?img=5', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW');
+INSERT INTO users (name, email, username, avatar_url, bio, birthdate, password, email_verified_at) VALUES ('Eve', 'eve@mail.com', 'eve', 'https://i.pravatar.cc/150?img=5', 'I am a software engineer', '1990-01-01', '$2a$10$7CuLzispLK.g/YCdW4uRrOu3PnS0..Z8VkcnB0.xVEiFLSySmNwPW', CURRENT_TIMESTAMP);
 
 INSERT INTO followers (user_id, follower_id) VALUES (1, 2);
 INSERT INTO followers (user_id, follower_id) VALUES (1, 3);
@@ -37,3 +37,20 @@ INSERT INTO likes (post_id, user_id) VALUES (3, 4);
 INSERT INTO likes (post_id, user_id) VALUES (3, 5);
 INSERT INTO likes (post_id, user_id) VALUES (4, 5);
 
+UPDATE users SET role = 'admin' WHERE email = 'alice@mail.com';
+
+INSERT INTO timelines (user_id, post_id, author_id, created_at)
+SELECT followers.follower_id, posts.id, posts.author_id, posts.created_at
+FROM posts
+JOIN followers ON followers.user_id = posts.author_id
+WHERE posts.parent_id IS NULL
+ON CONFLICT DO NOTHING;
+
+UPDATE posts SET
+    like_count = (SELECT COUNT(*) FROM likes WHERE likes.post_id = posts.id),
+    reply_count = (SELECT COUNT(*) FROM posts AS replies WHERE replies.parent_id = posts.id);
+
+UPDATE users SET
+    follower_count = (SELECT COUNT(*) FROM followers WHERE followers.user_id = users.id),
+    following_count = (SELECT COUNT(*) FROM followers WHERE followers.follower_id = users.id),
+    post_count = (SELECT COUNT(*) FROM posts WHERE posts.author_id = users.id AND posts.parent_id IS NULL);
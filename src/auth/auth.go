@@ -4,61 +4,109 @@ import (
 	"errors"
 	"net/http"
 	"project01/src/config"
+	"strconv"
 	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
-func CreateToken(userID uint64) (string, error) {
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["userID"] = userID
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
+var errInvalidToken = errors.New("invalid token")
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.SecretKey))
+// Claims are the claims of every JWT we issue. Subject is the user ID.
+// Purpose is empty for access tokens and names the flow for special-purpose
+// tokens such as two-factor challenges, so they can't be used as access
+// tokens.
+type Claims struct {
+	jwt.StandardClaims
+	SessionID string `json:"sid,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
 }
 
-func ValidateToken(r *http.Request) error {
-	tokenString := extractToken(r)
-	token, err := jwt.Parse(tokenString, ExtractSecretKey)
+// CreateToken issues a short-lived access token bound to a session.
+func CreateToken(userID uint64, sessionID string) (string, error) {
+	return issueToken(userID, config.AccessTokenTTL, Claims{SessionID: sessionID})
+}
+
+func issueToken(userID uint64, ttl time.Duration, claims Claims) (string, error) {
+	tokenID, err := randomHex(16)
 	if err != nil {
-		return err
+		return "", err
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if claims["authorized"] == true {
-			return nil
-		}
+
+	now := time.Now()
+
+	claims.StandardClaims = jwt.StandardClaims{
+		Subject:   strconv.FormatUint(userID, 10),
+		Issuer:    config.JWTIssuer,
+		Audience:  config.JWTAudience,
+		Id:        tokenID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
 	}
-	return errors.New("invalid token")
+
+	return signToken(claims)
 }
 
-func extractToken(r *http.Request) string {
-	bearerToken := r.Header.Get("Authorization")
-	strArr := strings.Split(bearerToken, " ")
-	if len(strArr) == 2 {
-		return strArr[1]
+// parseClaims verifies the signature, expiry, issuer and audience of a token
+// and checks that it was issued for purpose.
+func parseClaims(tokenString string, purpose string) (*Claims, uint64, error) {
+	var claims Claims
+
+	token, err := jwt.ParseWithClaims(tokenString, &claims, verificationKey)
+	if err != nil {
+		return nil, 0, err
 	}
-	return ""
+
+	if !token.Valid ||
+		claims.Issuer != config.JWTIssuer ||
+		!claims.VerifyAudience(config.JWTAudience, true) ||
+		claims.Purpose != purpose {
+		return nil, 0, errInvalidToken
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, 0, errInvalidToken
+	}
+
+	return &claims, userID, nil
 }
 
-func ExtractUserID(r *http.Request) (uint64, error) {
-	tokenString := extractToken(r)
-	token, err := jwt.Parse(tokenString, ExtractSecretKey)
+// ParseToken verifies the bearer token of a request and returns the principal
+// it was issued for.
+func ParseToken(r *http.Request) (*Principal, error) {
+	claims, userID, err := parseClaims(ExtractToken(r), "")
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	if claims.SessionID == "" {
+		return nil, errInvalidToken
+	}
+
+	principal := &Principal{
+		UserID:    userID,
+		TokenID:   claims.Id,
+		SessionID: claims.SessionID,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		Scopes:    []string{ScopeAll},
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := uint64(claims["userID"].(float64))
-		return userID, nil
+
+	if claims.Scope != "" {
+		principal.Scopes = strings.Fields(claims.Scope)
 	}
-	return 0, errors.New("invalid token")
+
+	return principal, nil
 }
 
-func ExtractSecretKey(token *jwt.Token) (interface{}, error) {
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-		return nil, errors.New("invalid token")
+// ExtractToken returns the bearer token of a request.
+func ExtractToken(r *http.Request) string {
+	bearerToken := r.Header.Get("Authorization")
+	strArr := strings.Split(bearerToken, " ")
+	if len(strArr) == 2 {
+		return strArr[1]
 	}
-	return config.SecretKey, nil
+	return ""
 }
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"errors"
+	"project01/src/config"
+	"time"
+)
+
+const challengePurpose = "2fa_challenge"
+
+var ErrInvalidChallenge = errors.New("invalid or expired challenge token")
+
+// CreateChallengeToken issues the short-lived token returned by the password
+// step of a two-factor login. It cannot be used as an access token.
+func CreateChallengeToken(userID uint64) (string, error) {
+	return issueToken(userID, config.ChallengeTokenTTL, Claims{Purpose: challengePurpose})
+}
+
+// Challenge is a parsed challenge token. TokenID and ExpiresAt let callers
+// record that the token was used.
+type Challenge struct {
+	UserID    uint64
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// ParseChallengeToken returns the challenge a token was issued for. It does
+// not know whether the token was already used.
+func ParseChallengeToken(tokenString string) (*Challenge, error) {
+	claims, userID, err := parseClaims(tokenString, challengePurpose)
+	if err != nil || claims.Id == "" {
+		return nil, ErrInvalidChallenge
+	}
+
+	return &Challenge{UserID: userID, TokenID: claims.Id, ExpiresAt: time.Unix(claims.ExpiresAt, 0)}, nil
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"project01/src/config"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Key is a JWT verification key, optionally with its private half. The key ID
+// is the file name it was loaded from, without the .pem extension.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+type keySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+var keys = &keySet{keys: map[string]*Key{}}
+
+// LoadKeys loads every *.pem file in config.JWTKeysDir. Private keys (PKCS#8
+// or PKCS#1) can sign and verify; public keys (PKIX) only verify, which is how
+// a rotated-out key is kept until the tokens it signed have expired. The key
+// named by config.JWTSigningKeyID signs new tokens.
+//
+// Without a keys directory tokens are signed with HS256 and config.SecretKey,
+// which is only meant for local development.
+func LoadKeys() error {
+	if config.JWTKeysDir == "" {
+		log.Println("JWT_KEYS_DIR is not set, signing tokens with HS256")
+		hmacKey := &Key{ID: "hs256", Method: jwt.SigningMethodHS256}
+		keys = &keySet{signing: hmacKey, keys: map[string]*Key{hmacKey.ID: hmacKey}}
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(config.JWTKeysDir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	set := &keySet{keys: map[string]*Key{}}
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		set.keys[key.ID] = key
+	}
+
+	set.signing = set.keys[config.JWTSigningKeyID]
+	if set.signing == nil || set.signing.PrivateKey == nil {
+		return fmt.Errorf("signing key %q not found in %s", config.JWTSigningKeyID, config.JWTKeysDir)
+	}
+
+	keys = set
+	return nil
+}
+
+func loadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = SigningMethodEd25519, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.PublicKey = SigningMethodEd25519, k
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	return key, nil
+}
+
+// signToken signs claims with the current signing key and sets its kid.
+func signToken(claims jwt.Claims) (string, error) {
+	signing := keys.signing
+	if signing == nil {
+		return "", errors.New("signing key not loaded")
+	}
+
+	token := jwt.NewWithClaims(signing.Method, claims)
+	token.Header["kid"] = signing.ID
+
+	if signing.PrivateKey == nil {
+		return token.SignedString(config.SecretKey)
+	}
+	return token.SignedString(signing.PrivateKey)
+}
+
+// verificationKey is the jwt.Keyfunc used to parse our tokens. It picks the
+// key named by the kid header and refuses any other algorithm than the one
+// that key was loaded for.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := keys.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("invalid signing method")
+	}
+
+	if key.PublicKey == nil {
+		return config.SecretKey, nil
+	}
+	return key.PublicKey, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS returns every public verification key, including retired ones.
+func JWKS() []JWK {
+	jwks := []JWK{}
+
+	for _, key := range keys.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+
+		switch publicKey := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		default:
+			continue
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNoPrincipal      = errors.New("unauthenticated")
+	ErrAccountSuspended = errors.New("account suspended")
+)
+
+// Principal is the authenticated caller of a request, as established by
+// middlewares.AuthMiddleware.
+type Principal struct {
+	UserID    uint64
+	Role      string
+	TokenID   string
+	SessionID string
+	Scopes    []string
+	IssuedAt  time.Time
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (*Principal, error) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	if !ok || principal == nil {
+		return nil, ErrNoPrincipal
+	}
+	return principal, nil
+}
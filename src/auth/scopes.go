@@ -0,0 +1,55 @@
+package auth
+
+import "fmt"
+
+// Scopes a personal access token can be granted. Session tokens carry
+// ScopeAll and may call every route.
+const (
+	ScopeAll                = "*"
+	ScopePostsRead          = "posts:read"
+	ScopePostsWrite         = "posts:write"
+	ScopeUsersRead          = "users:read"
+	ScopeUsersWrite         = "users:write"
+	ScopeFollowsRead        = "follows:read"
+	ScopeFollowsWrite       = "follows:write"
+	ScopeNotificationsRead  = "notifications:read"
+	ScopeNotificationsWrite = "notifications:write"
+)
+
+var grantableScopes = map[string]bool{
+	ScopePostsRead:          true,
+	ScopePostsWrite:         true,
+	ScopeUsersRead:          true,
+	ScopeUsersWrite:         true,
+	ScopeFollowsRead:        true,
+	ScopeFollowsWrite:       true,
+	ScopeNotificationsRead:  true,
+	ScopeNotificationsWrite: true,
+}
+
+// ValidateScopes checks that every scope may be granted to a personal access
+// token.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !grantableScopes[scope] {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	return nil
+}
+
+// HasScope reports whether the principal may call a route requiring scope.
+// Routes that declare no scope are reserved for session tokens.
+func (principal *Principal) HasScope(scope string) bool {
+	for _, granted := range principal.Scopes {
+		if granted == ScopeAll || (scope != "" && granted == scope) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// PersonalAccessTokenPrefix distinguishes personal access tokens from JWTs in
+// the Authorization header.
+const PersonalAccessTokenPrefix = "pat_"
+
+// NewSessionID returns a random identifier for a refresh token family.
+func NewSessionID() (string, error) {
+	return randomHex(16)
+}
+
+// NewOpaqueToken returns a random bearer token, such as a refresh or password
+// reset token, and the hash that should be persisted in its place.
+func NewOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes an opaque token for storage and lookup.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"project01/src/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid verification token")
+
+// CreateVerificationToken signs the user ID and email address so that a link
+// only verifies the address it was sent to.
+func CreateVerificationToken(userID uint64, email string) string {
+	expiresAt := time.Now().Add(config.VerificationTTL).Unix()
+	payload := strings.Join([]string{
+		strconv.FormatUint(userID, 10),
+		strconv.FormatInt(expiresAt, 10),
+		email,
+	}, "|")
+
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + sign(encoded)
+}
+
+// ParseVerificationToken checks the signature and expiry of a token created
+// by CreateVerificationToken.
+func ParseVerificationToken(token string) (uint64, string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(encoded))) {
+		return 0, "", ErrInvalidVerificationToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, "", ErrInvalidVerificationToken
+	}
+
+	parts := strings.SplitN(string(payload), "|", 3)
+	if len(parts) != 3 {
+		return 0, "", ErrInvalidVerificationToken
+	}
+
+	userID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", ErrInvalidVerificationToken
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, "", ErrInvalidVerificationToken
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return 0, "", errors.New("verification token expired")
+	}
+
+	return userID, parts[2], nil
+}
+
+func sign(value string) string {
+	mac := hmac.New(sha256.New, config.SecretKey)
+	mac.Write([]byte("email-verification:" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
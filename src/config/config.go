@@ -4,17 +4,108 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 var (
-	Host      string
-	Port      int
-	Username  string
-	Password  string
-	DBName    string
-	SecretKey []byte
+	Host            string
+	Port            int
+	Username        string
+	Password        string
+	DBName          string
+	SecretKey       []byte
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	JWTKeysDir      string
+	JWTSigningKeyID string
+	JWTIssuer       string
+	JWTAudience     string
+
+	TOTPIssuer        string
+	ChallengeTokenTTL time.Duration
+
+	// ThrottleStore selects where failed login counters live: "postgres"
+	// (shared by every instance) or "memory".
+	ThrottleStore      string
+	LoginMaxAttempts   int
+	LoginIPMaxAttempts int
+	LoginAttemptWindow time.Duration
+	LoginLockoutBase   time.Duration
+	LoginLockoutMax    time.Duration
+	TrustProxyHeaders  bool
+
+	AppURL           string
+	PasswordResetTTL time.Duration
+	// PasswordResetMaxRequests and PasswordResetIPMaxRequests bound how many
+	// reset emails one address, and one client IP, may ask for within
+	// PasswordResetWindow.
+	PasswordResetMaxRequests   int
+	PasswordResetIPMaxRequests int
+	PasswordResetWindow        time.Duration
+
+	// UnverifiedPolicy controls what accounts with an unverified email may do:
+	// "full", "read_only" or "none".
+	UnverifiedPolicy           string
+	VerificationTTL            time.Duration
+	VerificationResendInterval time.Duration
+
+	UsernameChangeCooldown time.Duration
+	UsernameRedirectTTL    time.Duration
+
+	TimelineFanOutLimit   int
+	TimelineBackfillLimit int
+	TimelineWorkers       int
+	TimelineQueueSize     int
+	// TimelineEnqueueTimeout is how long a request waits on a full timeline
+	// queue before the update is dropped.
+	TimelineEnqueueTimeout time.Duration
+
+	// StorageDriver selects where uploaded files are kept: "local" or "s3".
+	StorageDriver     string
+	StorageLocalDir   string
+	StoragePublicURL  string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	ImageMaxBytes     int
+	VideoMaxBytes     int
+	VideoMaxDuration  time.Duration
+
+	// MediaUnattachedTTL is how long an upload may wait to be used in a post
+	// before it is deleted.
+	MediaUnattachedTTL   time.Duration
+	MediaCleanupInterval time.Duration
+
+	// TrendsWindow is how far back GET /trends looks. Each use of a tag
+	// counts half as much every TrendsHalfLife.
+	TrendsWindow   time.Duration
+	TrendsHalfLife time.Duration
+	TrendsLimit    int
+
+	// PostEditWindow is how long after creation a post can be edited.
+	PostEditWindow time.Duration
+
+	// PostRetention is how long deleted posts keep their content before it
+	// is purged, e.g. for moderators to review removals.
+	PostRetention     time.Duration
+	PostPurgeInterval time.Duration
+
+	OIDCProviders map[string]OIDCProvider
+	OIDCStateTTL  time.Duration
+
+	MailDriver   string
+	MailFrom     string
+	MailLogPath  string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
 )
 
 func Load() {
@@ -34,4 +125,165 @@ func Load() {
 	if err != nil {
 		Port = 5432
 	}
+
+	AppURL = os.Getenv("APP_URL")
+	if AppURL == "" {
+		AppURL = "http://localhost:8080"
+	}
+
+	AccessTokenTTL = durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	RefreshTokenTTL = durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+
+	JWTKeysDir = os.Getenv("JWT_KEYS_DIR")
+	JWTSigningKeyID = os.Getenv("JWT_SIGNING_KEY_ID")
+	JWTIssuer = os.Getenv("JWT_ISSUER")
+	if JWTIssuer == "" {
+		JWTIssuer = AppURL
+	}
+	JWTAudience = os.Getenv("JWT_AUDIENCE")
+	if JWTAudience == "" {
+		JWTAudience = JWTIssuer
+	}
+
+	TOTPIssuer = os.Getenv("TOTP_ISSUER")
+	if TOTPIssuer == "" {
+		TOTPIssuer = "SocialMedia"
+	}
+	ChallengeTokenTTL = durationFromEnv("CHALLENGE_TOKEN_TTL", 5*time.Minute)
+
+	ThrottleStore = os.Getenv("THROTTLE_STORE")
+	LoginMaxAttempts = intFromEnv("LOGIN_MAX_ATTEMPTS", 5)
+	LoginIPMaxAttempts = intFromEnv("LOGIN_IP_MAX_ATTEMPTS", 50)
+	LoginAttemptWindow = durationFromEnv("LOGIN_ATTEMPT_WINDOW", 15*time.Minute)
+	LoginLockoutBase = durationFromEnv("LOGIN_LOCKOUT_BASE", time.Minute)
+	LoginLockoutMax = durationFromEnv("LOGIN_LOCKOUT_MAX", time.Hour)
+	TrustProxyHeaders = os.Getenv("TRUST_PROXY_HEADERS") == "true"
+
+	PasswordResetTTL = durationFromEnv("PASSWORD_RESET_TTL", time.Hour)
+	PasswordResetMaxRequests = intFromEnv("PASSWORD_RESET_MAX_REQUESTS", 3)
+	PasswordResetIPMaxRequests = intFromEnv("PASSWORD_RESET_IP_MAX_REQUESTS", 20)
+	PasswordResetWindow = durationFromEnv("PASSWORD_RESET_WINDOW", time.Hour)
+
+	UnverifiedPolicy = os.Getenv("UNVERIFIED_POLICY")
+	if UnverifiedPolicy == "" {
+		UnverifiedPolicy = "read_only"
+	}
+	VerificationTTL = durationFromEnv("VERIFICATION_TTL", 48*time.Hour)
+	VerificationResendInterval = durationFromEnv("VERIFICATION_RESEND_INTERVAL", time.Minute)
+
+	UsernameChangeCooldown = durationFromEnv("USERNAME_CHANGE_COOLDOWN", 30*24*time.Hour)
+	UsernameRedirectTTL = durationFromEnv("USERNAME_REDIRECT_TTL", 14*24*time.Hour)
+
+	TimelineFanOutLimit = intFromEnv("TIMELINE_FAN_OUT_LIMIT", 10000)
+	TimelineBackfillLimit = intFromEnv("TIMELINE_BACKFILL_LIMIT", 200)
+	TimelineWorkers = intFromEnv("TIMELINE_WORKERS", 4)
+	TimelineQueueSize = intFromEnv("TIMELINE_QUEUE_SIZE", 1000)
+	TimelineEnqueueTimeout = durationFromEnv("TIMELINE_ENQUEUE_TIMEOUT", 100*time.Millisecond)
+
+	StorageDriver = os.Getenv("STORAGE_DRIVER")
+	StorageLocalDir = os.Getenv("STORAGE_LOCAL_DIR")
+	if StorageLocalDir == "" {
+		StorageLocalDir = "uploads"
+	}
+	StoragePublicURL = os.Getenv("STORAGE_PUBLIC_URL")
+	S3Endpoint = os.Getenv("S3_ENDPOINT")
+	S3Region = os.Getenv("S3_REGION")
+	if S3Region == "" {
+		S3Region = "us-east-1"
+	}
+	S3Bucket = os.Getenv("S3_BUCKET")
+	S3AccessKeyID = os.Getenv("S3_ACCESS_KEY_ID")
+	S3SecretAccessKey = os.Getenv("S3_SECRET_ACCESS_KEY")
+	ImageMaxBytes = intFromEnv("IMAGE_MAX_BYTES", 5<<20)
+	VideoMaxBytes = intFromEnv("VIDEO_MAX_BYTES", 40<<20)
+	VideoMaxDuration = durationFromEnv("VIDEO_MAX_DURATION", 2*time.Minute)
+	MediaUnattachedTTL = durationFromEnv("MEDIA_UNATTACHED_TTL", 24*time.Hour)
+	MediaCleanupInterval = durationFromEnv("MEDIA_CLEANUP_INTERVAL", time.Hour)
+
+	TrendsWindow = durationFromEnv("TRENDS_WINDOW", 24*time.Hour)
+	TrendsHalfLife = durationFromEnv("TRENDS_HALF_LIFE", 4*time.Hour)
+	TrendsLimit = intFromEnv("TRENDS_LIMIT", 10)
+
+	PostEditWindow = durationFromEnv("POST_EDIT_WINDOW", time.Hour)
+	PostRetention = durationFromEnv("POST_RETENTION", 30*24*time.Hour)
+	PostPurgeInterval = durationFromEnv("POST_PURGE_INTERVAL", time.Hour)
+
+	OIDCProviders = oidcProvidersFromEnv()
+	OIDCStateTTL = durationFromEnv("OIDC_STATE_TTL", 10*time.Minute)
+
+	MailDriver = os.Getenv("MAIL_DRIVER")
+	MailFrom = os.Getenv("MAIL_FROM")
+	MailLogPath = os.Getenv("MAIL_LOG_PATH")
+	SMTPHost = os.Getenv("SMTP_HOST")
+	SMTPUsername = os.Getenv("SMTP_USERNAME")
+	SMTPPassword = os.Getenv("SMTP_PASSWORD")
+
+	SMTPPort, err = strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		SMTPPort = 587
+	}
+}
+
+// durationFromEnv parses a duration such as "15m" or "720h", falling back to
+// def when the variable is unset, invalid, zero or negative.
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// intFromEnv parses an integer, falling back to def when the variable is
+// unset or invalid.
+func intFromEnv(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// OIDCProvider configures an OpenID Connect identity provider users can sign
+// in with.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+}
+
+// oidcProvidersFromEnv reads the providers listed in OIDC_PROVIDERS, e.g.
+// "google,mock". Each one is configured by OIDC_<NAME>_ISSUER,
+// OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and optionally
+// OIDC_<NAME>_SCOPES.
+func oidcProvidersFromEnv() map[string]OIDCProvider {
+	providers := map[string]OIDCProvider{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		scopes := strings.Fields(os.Getenv(prefix + "SCOPES"))
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+
+		providers[name] = OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       scopes,
+			RedirectURL:  AppURL + "/auth/" + name + "/callback",
+		}
+	}
+
+	return providers
 }
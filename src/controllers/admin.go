@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"project01/src/auth"
+	"project01/src/models"
+	"project01/src/policy"
+	"project01/src/repositories"
+	"project01/src/response"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type AdminController struct {
+	UserRepo     repositories.UserRepositoryInterface
+	SessionRepo  repositories.SessionRepositoryInterface
+	AuditLogRepo repositories.AuditLogRepositoryInterface
+}
+
+func NewAdminController(db *sql.DB) *AdminController {
+	return &AdminController{
+		UserRepo:     repositories.NewUserRepository(db),
+		SessionRepo:  repositories.NewSessionRepository(db),
+		AuditLogRepo: repositories.NewAuditLogRepository(db),
+	}
+}
+
+// UpdateRole changes the role of a user
+func (ac *AdminController) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	parsedUserID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err = json.Unmarshal(requestBody, &body); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !policy.ValidRole(body.Role) {
+		response.ERROR(w, http.StatusBadRequest, errors.New("role must be one of user, moderator or admin"))
+		return
+	}
+
+	if parsedUserID == principal.UserID {
+		response.ERROR(w, http.StatusBadRequest, errors.New("you cannot change your own role"))
+		return
+	}
+
+	if err = ac.UserRepo.UpdateRole(parsedUserID, body.Role); err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	recordAudit(ac.AuditLogRepo, r, principal, policy.ManageRoles, "user", parsedUserID, map[string]string{"role": body.Role})
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// SuspendUser suspends a user and signs them out everywhere
+func (ac *AdminController) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	parsedUserID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if len(requestBody) > 0 {
+		if err = json.Unmarshal(requestBody, &body); err != nil {
+			response.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if parsedUserID == principal.UserID {
+		response.ERROR(w, http.StatusBadRequest, errors.New("you cannot suspend yourself"))
+		return
+	}
+
+	if err = ac.UserRepo.Suspend(parsedUserID); err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = ac.SessionRepo.RevokeAllForUser(parsedUserID); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	recordAudit(ac.AuditLogRepo, r, principal, policy.SuspendUser, "user", parsedUserID, map[string]string{"reason": body.Reason})
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// UnsuspendUser lifts the suspension of a user
+func (ac *AdminController) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	parsedUserID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = ac.UserRepo.Unsuspend(parsedUserID); err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	recordAudit(ac.AuditLogRepo, r, principal, policy.UnsuspendUser, "user", parsedUserID, nil)
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// FindAuditLogs returns the most recent privileged actions
+func (ac *AdminController) FindAuditLogs(w http.ResponseWriter, r *http.Request) {
+	entries, err := ac.AuditLogRepo.FindAll()
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if entries == nil {
+		entries = []models.AuditLog{}
+	}
+
+	response.JSON(w, http.StatusOK, entries)
+}
+
+// recordAudit records a privileged action. Failing to record it does not
+// fail the request, since the action has already been performed.
+func recordAudit(auditLogRepo repositories.AuditLogRepositoryInterface, r *http.Request, principal *auth.Principal, action policy.Action, targetType string, targetID uint64, metadata map[string]string) {
+	err := auditLogRepo.Create(models.AuditLog{
+		ActorID:    principal.UserID,
+		Action:     string(action),
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   metadata,
+		IPAddress:  clientIP(r),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"project01/src/auth"
+	"project01/src/config"
+	"project01/src/models"
+	"project01/src/oidc"
+	"project01/src/repositories"
+	"project01/src/response"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// bindingCookie holds the value that ties an authorization request to the
+// browser that started it.
+const bindingCookie = "oidc_binding"
+
+var (
+	errInvalidState     = errors.New("invalid or expired state")
+	errLastLoginMethod  = errors.New("cannot remove the only way to sign in, set a password first")
+	errEmailNotVerified = errors.New("the identity provider did not verify this email, sign in with your password and link the account instead")
+)
+
+type IdentityController struct {
+	UserRepo      repositories.UserRepositoryInterface
+	SessionRepo   repositories.SessionRepositoryInterface
+	TwoFactorRepo repositories.TwoFactorRepositoryInterface
+	IdentityRepo  repositories.IdentityRepositoryInterface
+}
+
+func NewIdentityController(db *sql.DB) *IdentityController {
+	return &IdentityController{
+		UserRepo:      repositories.NewUserRepository(db),
+		SessionRepo:   repositories.NewSessionRepository(db),
+		TwoFactorRepo: repositories.NewTwoFactorRepository(db),
+		IdentityRepo:  repositories.NewIdentityRepository(db),
+	}
+}
+
+// ProviderLogin redirects to the identity provider to sign in
+func (ic *IdentityController) ProviderLogin(w http.ResponseWriter, r *http.Request) {
+	authorizationURL, status, err := ic.authorize(w, r, nil)
+	if err != nil {
+		response.ERROR(w, status, err)
+		return
+	}
+
+	http.Redirect(w, r, authorizationURL, http.StatusFound)
+}
+
+// LinkIdentity starts connecting an identity provider account to the current
+// user. The client sends the user to the returned authorization URL in the
+// same browser, which keeps the cookie set by this response
+func (ic *IdentityController) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	authorizationURL, status, err := ic.authorize(w, r, &principal.UserID)
+	if err != nil {
+		response.ERROR(w, status, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{"authorization_url": authorizationURL})
+}
+
+// ProviderCallback completes an authorization request. It links the identity
+// when the request was started by LinkIdentity, and otherwise signs the user
+// in, creating an account on first sign-in
+func (ic *IdentityController) ProviderCallback(w http.ResponseWriter, r *http.Request) {
+	providerName := mux.Vars(r)["provider"]
+
+	provider, err := oidc.Get(providerName)
+	if err != nil {
+		response.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		response.ERROR(w, http.StatusBadRequest, errors.New(providerErr+": "+query.Get("error_description")))
+		return
+	}
+
+	state, err := ic.IdentityRepo.ConsumeState(auth.HashToken(query.Get("state")))
+	if err != nil {
+		if err == repositories.ErrNotFound || err == repositories.ErrTokenExpired {
+			response.ERROR(w, http.StatusBadRequest, errInvalidState)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	binding, err := r.Cookie(bindingCookie)
+	if err != nil || state.Provider != providerName ||
+		subtle.ConstantTimeCompare([]byte(auth.HashToken(binding.Value)), []byte(state.BindingHash)) != 1 {
+		response.ERROR(w, http.StatusBadRequest, errInvalidState)
+		return
+	}
+	http.SetCookie(w, newBindingCookie(providerName, "", -1))
+
+	idToken, err := provider.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	identity := models.Identity{
+		Provider: providerName,
+		Subject:  idToken.Subject,
+		Email:    strings.ToLower(idToken.Email),
+	}
+
+	if state.LinkUserID != nil {
+		ic.link(w, *state.LinkUserID, &identity)
+		return
+	}
+
+	userID, status, err := ic.resolveUser(idToken, &identity)
+	if err != nil {
+		response.ERROR(w, status, err)
+		return
+	}
+
+	access, err := ic.UserRepo.FindAccess(userID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if access.SuspendedAt != nil {
+		response.ERROR(w, http.StatusForbidden, auth.ErrAccountSuspended)
+		return
+	}
+
+	twoFactor, err := ic.TwoFactorRepo.Find(userID)
+	if err != nil && err != repositories.ErrNotFound {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if twoFactor != nil && twoFactor.Enabled() {
+		challengeToken, err := auth.CreateChallengeToken(userID)
+		if err != nil {
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusAccepted, models.TwoFactorChallenge{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+			ExpiresIn:         int64(config.ChallengeTokenTTL.Seconds()),
+		})
+		return
+	}
+
+	tokens, err := startSession(ic.SessionRepo, userID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tokens)
+}
+
+// FindAllIdentities lists the identity provider accounts linked to the
+// current user
+func (ic *IdentityController) FindAllIdentities(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	identities, err := ic.IdentityRepo.FindAll(principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if identities == nil {
+		identities = []models.Identity{}
+	}
+
+	response.JSON(w, http.StatusOK, identities)
+}
+
+// UnlinkIdentity removes a linked identity. The last identity of an account
+// without a password cannot be removed
+func (ic *IdentityController) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	identityID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	password, err := ic.UserRepo.FindPassword(principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if password == "" {
+		count, err := ic.IdentityRepo.Count(principal.UserID)
+		if err != nil {
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if count <= 1 {
+			response.ERROR(w, http.StatusConflict, errLastLoginMethod)
+			return
+		}
+	}
+
+	if err = ic.IdentityRepo.Delete(principal.UserID, identityID); err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// authorize stores a new authorization request, bound to the browser with a
+// cookie, and returns the provider URL to send the user to
+func (ic *IdentityController) authorize(w http.ResponseWriter, r *http.Request, linkUserID *uint64) (string, int, error) {
+	providerName := mux.Vars(r)["provider"]
+
+	provider, err := oidc.Get(providerName)
+	if err != nil {
+		return "", http.StatusNotFound, err
+	}
+
+	state, err := oidc.NewNonce()
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	binding, err := oidc.NewNonce()
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	authorizationURL, err := provider.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		return "", http.StatusBadGateway, err
+	}
+
+	err = ic.IdentityRepo.CreateState(&models.OAuthState{
+		StateHash:    auth.HashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		BindingHash:  auth.HashToken(binding),
+		ExpiresAt:    time.Now().Add(config.OIDCStateTTL),
+	})
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	http.SetCookie(w, newBindingCookie(providerName, binding, int(config.OIDCStateTTL.Seconds())))
+
+	return authorizationURL, 0, nil
+}
+
+// newBindingCookie returns the binding cookie for a provider. It is only sent
+// to the provider's callback, and a negative maxAge deletes it.
+func newBindingCookie(providerName, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     bindingCookie,
+		Value:    value,
+		Path:     "/auth/" + providerName + "/callback",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.AppURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (ic *IdentityController) link(w http.ResponseWriter, userID uint64, identity *models.Identity) {
+	existing, err := ic.IdentityRepo.FindByProviderSubject(identity.Provider, identity.Subject)
+	if err != nil && err != repositories.ErrNotFound {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if existing != nil {
+		if existing.UserID != userID {
+			response.ERROR(w, http.StatusConflict, repositories.ErrIdentityTaken)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, existing)
+		return
+	}
+
+	identity.UserID = userID
+	if err = ic.IdentityRepo.Create(identity); err != nil {
+		if err == repositories.ErrIdentityTaken {
+			response.ERROR(w, http.StatusConflict, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, identity)
+}
+
+// resolveUser finds the user an identity signs in as. An unknown identity is
+// linked to the account with the same email only when both the provider and
+// the account have verified it; otherwise a new account is created.
+func (ic *IdentityController) resolveUser(idToken *oidc.IDToken, identity *models.Identity) (uint64, int, error) {
+	existing, err := ic.IdentityRepo.FindByProviderSubject(identity.Provider, identity.Subject)
+	if err == nil {
+		return existing.UserID, 0, nil
+	}
+	if err != repositories.ErrNotFound {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	if identity.Email == "" || !bool(idToken.EmailVerified) {
+		return 0, http.StatusForbidden, errEmailNotVerified
+	}
+
+	user, err := ic.UserRepo.FindByEmail(identity.Email)
+	if err != nil && err != repositories.ErrNotFound {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	if user != nil {
+		verified, err := ic.UserRepo.IsEmailVerified(user.ID)
+		if err != nil {
+			return 0, http.StatusInternalServerError, err
+		}
+
+		// Linking to an unverified account would let whoever registered the
+		// address first take over the provider sign-in.
+		if !verified {
+			return 0, http.StatusConflict, errors.New("an account with this email exists, sign in with your password and link the account instead")
+		}
+
+		identity.UserID = user.ID
+		if err = ic.IdentityRepo.Create(identity); err != nil && err != repositories.ErrIdentityTaken {
+			return 0, http.StatusInternalServerError, err
+		}
+
+		return user.ID, 0, nil
+	}
+
+	name := idToken.Name
+	if name == "" {
+		name = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+
+	newUser := models.User{
+		Name:      name,
+		Email:     identity.Email,
+		Username:  usernameFromIdentity(idToken.PreferredUsername, identity.Email),
+		AvatarURL: idToken.Picture,
+	}
+	if err = ic.IdentityRepo.CreateWithUser(&newUser, identity); err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	return newUser.ID, 0, nil
+}
+
+// usernameFromIdentity derives a username from the provider's preferred
+// username or the local part of the email
+func usernameFromIdentity(preferred, email string) string {
+	candidate := preferred
+	if candidate == "" {
+		candidate = email
+	}
+	if at := strings.Index(candidate, "@"); at >= 0 {
+		candidate = candidate[:at]
+	}
+
+	var username strings.Builder
+	for _, c := range strings.ToLower(candidate) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '.' {
+			username.WriteRune(c)
+		}
+		if username.Len() == 30 {
+			break
+		}
+	}
+
+	if models.ValidateUsername(username.String()) != nil {
+		return "user"
+	}
+
+	return username.String()
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"project01/src/auth"
+	"project01/src/config"
+	"project01/src/models"
+	"project01/src/oidc/oidctest"
+	"project01/src/repositories"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type fakeUserRepo struct {
+	repositories.UserRepositoryInterface
+	users    []models.User
+	verified map[uint64]bool
+}
+
+func (r *fakeUserRepo) FindByEmail(email string) (*models.User, error) {
+	for i := range r.users {
+		if r.users[i].Email == email {
+			return &r.users[i], nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r *fakeUserRepo) IsEmailVerified(id uint64) (bool, error) {
+	return r.verified[id], nil
+}
+
+func (r *fakeUserRepo) FindAccess(id uint64) (*models.User, error) {
+	return &models.User{ID: id}, nil
+}
+
+type fakeIdentityRepo struct {
+	repositories.IdentityRepositoryInterface
+	states     map[string]models.OAuthState
+	identities []models.Identity
+	created    []models.User
+}
+
+func (r *fakeIdentityRepo) FindByProviderSubject(provider, subject string) (*models.Identity, error) {
+	for i := range r.identities {
+		if r.identities[i].Provider == provider && r.identities[i].Subject == subject {
+			return &r.identities[i], nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r *fakeIdentityRepo) Create(identity *models.Identity) error {
+	if existing, _ := r.FindByProviderSubject(identity.Provider, identity.Subject); existing != nil {
+		return repositories.ErrIdentityTaken
+	}
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *fakeIdentityRepo) CreateWithUser(user *models.User, identity *models.Identity) error {
+	user.ID = uint64(100 + len(r.created))
+	r.created = append(r.created, *user)
+	identity.UserID = user.ID
+	return r.Create(identity)
+}
+
+func (r *fakeIdentityRepo) CreateState(state *models.OAuthState) error {
+	r.states[state.StateHash] = *state
+	return nil
+}
+
+func (r *fakeIdentityRepo) ConsumeState(stateHash string) (*models.OAuthState, error) {
+	state, ok := r.states[stateHash]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+	delete(r.states, stateHash)
+	return &state, nil
+}
+
+type fakeTwoFactorRepo struct {
+	repositories.TwoFactorRepositoryInterface
+}
+
+func (r *fakeTwoFactorRepo) Find(userID uint64) (*models.TwoFactor, error) {
+	return nil, repositories.ErrNotFound
+}
+
+type fakeSessionRepo struct {
+	repositories.SessionRepositoryInterface
+}
+
+func (r *fakeSessionRepo) Create(session *models.Session) error {
+	return nil
+}
+
+// newIdentityTest starts a mock provider registered as providerName and
+// returns a controller whose repositories are kept in memory.
+func newIdentityTest(t *testing.T, providerName string) (*IdentityController, *fakeUserRepo, *fakeIdentityRepo, *oidctest.Server) {
+	t.Helper()
+
+	server := oidctest.NewServer("social-media")
+	t.Cleanup(server.Close)
+
+	config.SecretKey = []byte("test secret")
+	config.JWTIssuer, config.JWTAudience = "test", "test"
+	config.AccessTokenTTL, config.RefreshTokenTTL = time.Minute, time.Hour
+	config.OIDCStateTTL = time.Minute
+	if config.OIDCProviders == nil {
+		config.OIDCProviders = map[string]config.OIDCProvider{}
+	}
+	config.OIDCProviders[providerName] = config.OIDCProvider{
+		Name:        providerName,
+		Issuer:      server.Issuer(),
+		ClientID:    "social-media",
+		Scopes:      []string{"openid", "email", "profile"},
+		RedirectURL: "http://localhost:8080/auth/" + providerName + "/callback",
+	}
+	if err := auth.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	userRepo := &fakeUserRepo{verified: map[uint64]bool{}}
+	identityRepo := &fakeIdentityRepo{states: map[string]models.OAuthState{}}
+	controller := &IdentityController{
+		UserRepo:      userRepo,
+		SessionRepo:   &fakeSessionRepo{},
+		TwoFactorRepo: &fakeTwoFactorRepo{},
+		IdentityRepo:  identityRepo,
+	}
+
+	return controller, userRepo, identityRepo, server
+}
+
+// signInWithProvider runs ProviderLogin, the provider's authorization and
+// ProviderCallback, and returns the callback's response. The binding cookie
+// is only sent back when keepCookie is set.
+func signInWithProvider(t *testing.T, controller *IdentityController, server *oidctest.Server, providerName string, keepCookie bool) *httptest.ResponseRecorder {
+	t.Helper()
+
+	vars := map[string]string{"provider": providerName}
+
+	login := httptest.NewRecorder()
+	controller.ProviderLogin(login, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/auth/"+providerName+"/login", nil), vars))
+	if login.Code != http.StatusFound {
+		t.Fatalf("login answered %d: %s", login.Code, login.Body)
+	}
+
+	callbackURL, err := server.Authorize(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, callbackURL.String(), nil), vars)
+	if keepCookie {
+		for _, cookie := range login.Result().Cookies() {
+			request.AddCookie(cookie)
+		}
+	}
+
+	callback := httptest.NewRecorder()
+	controller.ProviderCallback(callback, request)
+	return callback
+}
+
+func TestProviderCallbackLinksVerifiedEmail(t *testing.T) {
+	controller, userRepo, identityRepo, server := newIdentityTest(t, "verified")
+	userRepo.users = []models.User{{ID: 7, Email: "alice@example.com"}}
+	userRepo.verified[7] = true
+	server.User = oidctest.User{Subject: "alice-sub", Email: "Alice@example.com", EmailVerified: true}
+
+	callback := signInWithProvider(t, controller, server, "verified", true)
+	if callback.Code != http.StatusOK {
+		t.Fatalf("callback answered %d: %s", callback.Code, callback.Body)
+	}
+
+	if len(identityRepo.identities) != 1 || identityRepo.identities[0].UserID != 7 {
+		t.Fatalf("identity not linked to the existing account: %+v", identityRepo.identities)
+	}
+
+	if len(identityRepo.created) != 0 {
+		t.Fatalf("unexpected new account: %+v", identityRepo.created)
+	}
+}
+
+func TestProviderCallbackRefusesUnverifiedEmail(t *testing.T) {
+	controller, userRepo, identityRepo, server := newIdentityTest(t, "unverified")
+	userRepo.users = []models.User{{ID: 7, Email: "alice@example.com"}}
+	userRepo.verified[7] = true
+	server.User = oidctest.User{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: false}
+
+	callback := signInWithProvider(t, controller, server, "unverified", true)
+	if callback.Code != http.StatusForbidden {
+		t.Fatalf("callback answered %d, want 403: %s", callback.Code, callback.Body)
+	}
+
+	if len(identityRepo.identities) != 0 || len(identityRepo.created) != 0 {
+		t.Fatalf("unverified email was linked: %+v %+v", identityRepo.identities, identityRepo.created)
+	}
+}
+
+func TestProviderCallbackRefusesUnverifiedAccount(t *testing.T) {
+	controller, userRepo, identityRepo, server := newIdentityTest(t, "unverified-account")
+	userRepo.users = []models.User{{ID: 7, Email: "alice@example.com"}}
+	server.User = oidctest.User{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true}
+
+	callback := signInWithProvider(t, controller, server, "unverified-account", true)
+	if callback.Code != http.StatusConflict {
+		t.Fatalf("callback answered %d, want 409: %s", callback.Code, callback.Body)
+	}
+
+	if len(identityRepo.identities) != 0 {
+		t.Fatalf("identity linked to an account with an unverified email: %+v", identityRepo.identities)
+	}
+}
+
+func TestProviderCallbackRequiresBindingCookie(t *testing.T) {
+	controller, _, identityRepo, server := newIdentityTest(t, "binding")
+	server.User = oidctest.User{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true}
+
+	callback := signInWithProvider(t, controller, server, "binding", false)
+	if callback.Code != http.StatusBadRequest {
+		t.Fatalf("callback without the binding cookie answered %d, want 400: %s", callback.Code, callback.Body)
+	}
+
+	if len(identityRepo.identities) != 0 || len(identityRepo.created) != 0 {
+		t.Fatalf("callback without the binding cookie signed in: %+v %+v", identityRepo.identities, identityRepo.created)
+	}
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"net/http"
+	"project01/src/auth"
+	"project01/src/response"
+)
+
+// JWKS publishes the public keys that verify our access tokens
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	response.JSON(w, http.StatusOK, struct {
+		Keys []auth.JWK `json:"keys"`
+	}{
+		Keys: auth.JWKS(),
+	})
+}
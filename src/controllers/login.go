@@ -1,20 +1,84 @@
 package controllers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"project01/src/auth"
-	"project01/src/db"
+	"project01/src/config"
 	"project01/src/models"
 	"project01/src/repositories"
 	"project01/src/response"
+	"project01/src/throttle"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Login authenticates a user
-func Login(w http.ResponseWriter, r *http.Request) {
+var errInvalidCredentials = errors.New("invalid email or password")
+
+// dummyPasswordHash is compared against when the email is unknown, so that a
+// login takes as long whether or not the email is registered. Its cost must
+// stay that of models.HashPassword.
+const dummyPasswordHash = "$2a$10$Hcwx3V7BFEBpengDW5ZpAuhA413UfIs3FS7xPDbFtDNiVYI5m6jt2"
+
+type LoginController struct {
+	UserRepo         repositories.UserRepositoryInterface
+	SessionRepo      repositories.SessionRepositoryInterface
+	TwoFactorRepo    repositories.TwoFactorRepositoryInterface
+	LoginAttemptRepo repositories.LoginAttemptRepositoryInterface
+	// EmailLimiter locks out password attempts by email, whether or not the
+	// email has an account, so that a lockout does not reveal which do.
+	// AccountLimiter locks out two-factor attempts by account.
+	EmailLimiter   *throttle.Limiter
+	AccountLimiter *throttle.Limiter
+	IPLimiter      *throttle.Limiter
+}
+
+func NewLoginController(db *sql.DB) *LoginController {
+	store := throttle.NewStore(db)
+
+	return &LoginController{
+		UserRepo:         repositories.NewUserRepository(db),
+		SessionRepo:      repositories.NewSessionRepository(db),
+		TwoFactorRepo:    repositories.NewTwoFactorRepository(db),
+		LoginAttemptRepo: repositories.NewLoginAttemptRepository(db),
+		EmailLimiter: &throttle.Limiter{
+			Store:     store,
+			Prefix:    "email:",
+			Threshold: config.LoginMaxAttempts,
+			BaseDelay: config.LoginLockoutBase,
+			MaxDelay:  config.LoginLockoutMax,
+			Window:    config.LoginAttemptWindow,
+		},
+		AccountLimiter: &throttle.Limiter{
+			Store:     store,
+			Prefix:    "user:",
+			Threshold: config.LoginMaxAttempts,
+			BaseDelay: config.LoginLockoutBase,
+			MaxDelay:  config.LoginLockoutMax,
+			Window:    config.LoginAttemptWindow,
+		},
+		IPLimiter: &throttle.Limiter{
+			Store:     store,
+			Prefix:    "ip:",
+			Threshold: config.LoginIPMaxAttempts,
+			BaseDelay: config.LoginLockoutBase,
+			MaxDelay:  config.LoginLockoutMax,
+			Window:    config.LoginAttemptWindow,
+		},
+	}
+}
+
+// Login authenticates a user. Users with two-factor authentication enabled
+// get a challenge token to exchange at /login/2fa instead of a token pair
+func (lc *LoginController) Login(w http.ResponseWriter, r *http.Request) {
 	requestBody, err := io.ReadAll(r.Body)
 	if err != nil {
 		response.ERROR(w, http.StatusUnprocessableEntity, err)
@@ -27,31 +91,378 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := db.New()
-	if err != nil {
-		response.ERROR(w, http.StatusInternalServerError, err)
+	emailKey := strings.ToLower(strings.TrimSpace(user.Email))
+	if lc.lockedOut(w, lc.IPLimiter, clientIP(r)) || lc.lockedOut(w, lc.EmailLimiter, emailKey) {
 		return
 	}
-	defer db.Close()
 
-	userRepo := repositories.UserRepository{DB: db}
-	userSaved, err := userRepo.FindByEmail(user.Email)
+	userSaved, err := lc.UserRepo.FindByEmail(user.Email)
 	if err != nil {
+		if err == repositories.ErrNotFound {
+			bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(user.Password))
+			lc.loginFailed(w, r, lc.EmailLimiter, emailKey, nil, user.Email, "unknown_email", errInvalidCredentials)
+			return
+		}
+
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	passwordErr := bcrypt.CompareHashAndPassword([]byte(userSaved.Password), []byte(user.Password))
 	if passwordErr != nil {
-		response.ERROR(w, http.StatusUnauthorized, nil)
+		lc.loginFailed(w, r, lc.EmailLimiter, emailKey, &userSaved.ID, user.Email, "invalid_password", errInvalidCredentials)
+		return
+	}
+
+	if userSaved.SuspendedAt != nil {
+		lc.recordAttempt(r, &userSaved.ID, user.Email, false, "suspended")
+		response.ERROR(w, http.StatusForbidden, auth.ErrAccountSuspended)
+		return
+	}
+
+	twoFactor, err := lc.TwoFactorRepo.Find(userSaved.ID)
+	if err != nil && err != repositories.ErrNotFound {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if twoFactor != nil && twoFactor.Enabled() {
+		challengeToken, err := auth.CreateChallengeToken(userSaved.ID)
+		if err != nil {
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusAccepted, models.TwoFactorChallenge{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+			ExpiresIn:         int64(config.ChallengeTokenTTL.Seconds()),
+		})
+		return
+	}
+
+	lc.loginSucceeded(r, userSaved.ID, user.Email)
+
+	tokens, err := startSession(lc.SessionRepo, userSaved.ID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tokens)
+}
+
+// LoginTwoFactor exchanges a challenge token and a TOTP or recovery code for
+// a token pair. Each challenge token signs in once
+func (lc *LoginController) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	body, err := readTwoFactorCode(r)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	challenge, err := auth.ParseChallengeToken(body.ChallengeToken)
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
+	userID := challenge.UserID
 
-	token, err := auth.CreateToken(userSaved.ID)
+	twoFactor, err := lc.TwoFactorRepo.Find(userID)
 	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusUnauthorized, auth.ErrInvalidChallenge)
+			return
+		}
+
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	response.JSON(w, http.StatusOK, token)
+	if !twoFactor.Enabled() {
+		response.ERROR(w, http.StatusUnauthorized, auth.ErrInvalidChallenge)
+		return
+	}
+
+	if lc.lockedOut(w, lc.IPLimiter, clientIP(r)) || lc.lockedOut(w, lc.AccountLimiter, strconv.FormatUint(userID, 10)) {
+		return
+	}
+
+	var valid bool
+	if body.RecoveryCode != "" {
+		valid, err = lc.TwoFactorRepo.UseRecoveryCode(userID, auth.HashToken(auth.NormalizeRecoveryCode(body.RecoveryCode)))
+	} else {
+		valid, err = verifyTOTP(lc.TwoFactorRepo, twoFactor, body.Code)
+	}
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !valid {
+		lc.loginFailed(w, r, lc.AccountLimiter, strconv.FormatUint(userID, 10), &userID, "", "invalid_2fa_code", errInvalidTwoFactorCode)
+		return
+	}
+
+	unused, err := lc.TwoFactorRepo.UseChallenge(challenge.TokenID, challenge.ExpiresAt)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !unused {
+		response.ERROR(w, http.StatusUnauthorized, auth.ErrInvalidChallenge)
+		return
+	}
+
+	lc.loginSucceeded(r, userID, "")
+
+	tokens, err := startSession(lc.SessionRepo, userID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tokens)
+}
+
+// RefreshToken exchanges a refresh token for a new token pair
+func (lc *LoginController) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err = json.Unmarshal(requestBody, &body); err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if body.RefreshToken == "" {
+		response.ERROR(w, http.StatusBadRequest, errors.New("refresh_token is required"))
+		return
+	}
+
+	refreshToken, refreshTokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	session, err := lc.SessionRepo.Rotate(auth.HashToken(body.RefreshToken), refreshTokenHash, time.Now().Add(config.RefreshTokenTTL))
+	if err != nil {
+		switch err {
+		case repositories.ErrNotFound:
+			response.ERROR(w, http.StatusUnauthorized, errors.New("invalid refresh token"))
+		case repositories.ErrSessionRevoked, repositories.ErrSessionExpired, repositories.ErrTokenReused:
+			response.ERROR(w, http.StatusUnauthorized, err)
+		default:
+			response.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	tokens, err := tokenPair(session, refreshToken)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tokens)
+}
+
+// Logout revokes the session of the current access token
+func (lc *LoginController) Logout(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err = lc.SessionRepo.RevokeFamily(principal.SessionID); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// LogoutAll revokes every session of the current user
+func (lc *LoginController) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err = lc.SessionRepo.RevokeAllForUser(principal.UserID); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// LoginAttempts returns the recent login attempts against the current user's
+// account
+func (lc *LoginController) LoginAttempts(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	attempts, err := lc.LoginAttemptRepo.FindByUserID(principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(attempts) == 0 {
+		response.JSON(w, http.StatusOK, []models.LoginAttempt{})
+		return
+	}
+
+	response.JSON(w, http.StatusOK, attempts)
+}
+
+// lockedOut answers 429 with a Retry-After header when id is locked out by
+// limiter.
+func (lc *LoginController) lockedOut(w http.ResponseWriter, limiter *throttle.Limiter, id string) bool {
+	wait, err := limiter.Check(id)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return true
+	}
+
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return true
+	}
+
+	return false
+}
+
+// loginFailed records a failed attempt against the client IP and against key
+// in limiter. It answers with err, or with 429 if this failure triggered a
+// lockout.
+func (lc *LoginController) loginFailed(w http.ResponseWriter, r *http.Request, limiter *throttle.Limiter, key string, userID *uint64, email string, reason string, err error) {
+	lc.recordAttempt(r, userID, email, false, reason)
+
+	wait, limiterErr := lc.IPLimiter.Fail(clientIP(r))
+	if limiterErr != nil {
+		response.ERROR(w, http.StatusInternalServerError, limiterErr)
+		return
+	}
+
+	keyWait, limiterErr := limiter.Fail(key)
+	if limiterErr != nil {
+		response.ERROR(w, http.StatusInternalServerError, limiterErr)
+		return
+	}
+
+	if keyWait > wait {
+		wait = keyWait
+	}
+
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	response.ERROR(w, http.StatusUnauthorized, err)
+}
+
+func (lc *LoginController) loginSucceeded(r *http.Request, userID uint64, email string) {
+	lc.recordAttempt(r, &userID, email, true, "")
+
+	if err := lc.AccountLimiter.Reset(strconv.FormatUint(userID, 10)); err != nil {
+		log.Println(err)
+	}
+
+	if email != "" {
+		if err := lc.EmailLimiter.Reset(strings.ToLower(strings.TrimSpace(email))); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (lc *LoginController) recordAttempt(r *http.Request, userID *uint64, email string, success bool, reason string) {
+	err := lc.LoginAttemptRepo.Create(models.LoginAttempt{
+		UserID:    userID,
+		Email:     email,
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	response.ERROR(w, http.StatusTooManyRequests, errors.New("too many failed login attempts, try again later"))
+}
+
+// clientIP returns the address of the client, honouring X-Forwarded-For only
+// when config.TrustProxyHeaders is set.
+func clientIP(r *http.Request) string {
+	if config.TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// startSession opens a new refresh token family for a user and returns its
+// first token pair.
+func startSession(sessionRepo repositories.SessionRepositoryInterface, userID uint64) (*models.TokenPair, error) {
+	familyID, err := auth.NewSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshTokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := models.Session{
+		FamilyID:  familyID,
+		UserID:    userID,
+		TokenHash: refreshTokenHash,
+		ExpiresAt: time.Now().Add(config.RefreshTokenTTL),
+	}
+	if err = sessionRepo.Create(&session); err != nil {
+		return nil, err
+	}
+
+	return tokenPair(&session, refreshToken)
+}
+
+func tokenPair(session *models.Session, refreshToken string) (*models.TokenPair, error) {
+	accessToken, err := auth.CreateToken(session.UserID, session.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(config.AccessTokenTTL.Seconds()),
+	}, nil
 }
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"project01/src/auth"
+	"project01/src/config"
+	"project01/src/media"
+	"project01/src/models"
+	"project01/src/repositories"
+	"project01/src/response"
+	"project01/src/storage"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type MediaController struct {
+	MediaRepo repositories.MediaRepositoryInterface
+	Storage   storage.Storage
+}
+
+func NewMediaController(db *sql.DB) *MediaController {
+	return &MediaController{
+		MediaRepo: repositories.NewMediaRepository(db),
+		Storage:   storage.New(),
+	}
+}
+
+// UploadMedia stores the file in the "file" field of a multipart form, with
+// optional "alt_text", as an attachment the current user can add to a post
+func (mc *MediaController) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	maxBytes := max(config.ImageMaxBytes, config.VideoMaxBytes)
+	// Leave room for the multipart headers and the alt text.
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes)+64<<10)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.ERROR(w, http.StatusRequestEntityTooLarge, fmt.Errorf("file must be at most %d bytes", maxBytes))
+			return
+		}
+
+		response.ERROR(w, http.StatusBadRequest, errors.New("file is required"))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(maxBytes)+1))
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	kind, err := media.SniffMedia(data)
+	if err != nil {
+		response.ERROR(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	limit := config.ImageMaxBytes
+	if kind == "video" {
+		limit = config.VideoMaxBytes
+	}
+	if len(data) > limit {
+		response.ERROR(w, http.StatusRequestEntityTooLarge, fmt.Errorf("%s must be at most %d bytes", kind, limit))
+		return
+	}
+
+	altText, err := models.PrepareAltText(r.FormValue("alt_text"))
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	processed, err := media.ProcessAttachment(data, config.VideoMaxDuration)
+	if err != nil {
+		switch err {
+		case media.ErrUnsupportedMedia, media.ErrUnsupportedImage:
+			response.ERROR(w, http.StatusUnsupportedMediaType, err)
+		case media.ErrImageTooLarge, media.ErrInvalidVideo, media.ErrVideoTooLong:
+			response.ERROR(w, http.StatusUnprocessableEntity, err)
+		default:
+			response.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	key, thumbnailKey, err := media.NewAttachmentKeys(principal.UserID, processed.Extension)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	attachment := models.MediaAttachment{
+		UserID:      principal.UserID,
+		Kind:        processed.Kind,
+		ContentType: processed.ContentType,
+		URL:         mc.Storage.URL(key),
+		Key:         key,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		DurationMS:  processed.Duration.Milliseconds(),
+		Size:        int64(len(processed.Data)),
+		Blurhash:    processed.Blurhash,
+		AltText:     altText,
+	}
+
+	if err = mc.Storage.Put(r.Context(), key, processed.ContentType, processed.Data); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if processed.Thumbnail != nil {
+		attachment.ThumbnailURL, attachment.ThumbnailKey = mc.Storage.URL(thumbnailKey), thumbnailKey
+
+		if err = mc.Storage.Put(r.Context(), thumbnailKey, "image/jpeg", processed.Thumbnail); err != nil {
+			mc.deleteFiles(r, key)
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if err = mc.MediaRepo.Create(&attachment); err != nil {
+		mc.deleteFiles(r, attachment.Key, attachment.ThumbnailKey)
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, attachment)
+}
+
+// FindMedia returns an attachment
+func (mc *MediaController) FindMedia(w http.ResponseWriter, r *http.Request) {
+	mediaID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	attachment, err := mc.MediaRepo.FindByID(mediaID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, attachment)
+}
+
+// UpdateMedia changes the alt text of an attachment the current user
+// uploaded
+func (mc *MediaController) UpdateMedia(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	mediaID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responseBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var body struct {
+		AltText string `json:"alt_text"`
+	}
+	if err = json.Unmarshal(responseBody, &body); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	altText, err := models.PrepareAltText(body.AltText)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = mc.MediaRepo.UpdateAltText(mediaID, principal.UserID, altText); err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	attachment, err := mc.MediaRepo.FindByID(mediaID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, attachment)
+}
+
+// deleteFiles removes files of an upload that failed. Files left behind are
+// removed by the media garbage collector
+func (mc *MediaController) deleteFiles(r *http.Request, keys ...string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		if err := mc.Storage.Delete(r.Context(), key); err != nil {
+			log.Println(err)
+		}
+	}
+}
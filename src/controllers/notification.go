@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"net/http"
 	"project01/src/auth"
-	"project01/src/models"
 	"project01/src/repositories"
 	"project01/src/response"
 	"strconv"
@@ -22,22 +21,23 @@ func NewNotificationController(db *sql.DB) *NotificationController {
 	}
 }
 
-// FindAllNotifications returns all notifications for a user
+// FindAllNotifications returns a page of notifications for a user
 func (nc *NotificationController) FindAllNotifications(w http.ResponseWriter, r *http.Request) {
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	notifications, err := nc.NotificationRepo.FindAll(userIDFromToken)
+	page, err := pageRequest(r)
 	if err != nil {
-		response.ERROR(w, http.StatusInternalServerError, err)
+		response.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if len(notifications) == 0 {
-		response.JSON(w, http.StatusOK, []models.Notification{})
+	notifications, err := nc.NotificationRepo.FindAll(principal.UserID, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -46,7 +46,7 @@ func (nc *NotificationController) FindAllNotifications(w http.ResponseWriter, r
 
 // FindNotificationByID returns a notification by ID
 func (nc *NotificationController) FindNotificationByID(w http.ResponseWriter, r *http.Request) {
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
@@ -61,7 +61,7 @@ func (nc *NotificationController) FindNotificationByID(w http.ResponseWriter, r
 		return
 	}
 
-	notification, err := nc.NotificationRepo.FindByID(userIDFromToken, parsedID)
+	notification, err := nc.NotificationRepo.FindByID(principal.UserID, parsedID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
@@ -72,7 +72,7 @@ func (nc *NotificationController) FindNotificationByID(w http.ResponseWriter, r
 
 // DeleteNotification deletes a notification
 func (nc *NotificationController) DeleteNotification(w http.ResponseWriter, r *http.Request) {
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
@@ -87,18 +87,18 @@ func (nc *NotificationController) DeleteNotification(w http.ResponseWriter, r *h
 		return
 	}
 
-	notification, err := nc.NotificationRepo.FindByID(userIDFromToken, parsedID)
+	notification, err := nc.NotificationRepo.FindByID(principal.UserID, parsedID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	if userIDFromToken != notification.UserID {
+	if principal.UserID != notification.UserID {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	err = nc.NotificationRepo.Delete(userIDFromToken, parsedID)
+	err = nc.NotificationRepo.Delete(principal.UserID, parsedID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
@@ -109,7 +109,7 @@ func (nc *NotificationController) DeleteNotification(w http.ResponseWriter, r *h
 
 // MarkNotificationAsRead marks a notification as read
 func (nc *NotificationController) MarkNotificationAsRead(w http.ResponseWriter, r *http.Request) {
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
@@ -124,18 +124,18 @@ func (nc *NotificationController) MarkNotificationAsRead(w http.ResponseWriter,
 		return
 	}
 
-	notification, err := nc.NotificationRepo.FindByID(userIDFromToken, parsedID)
+	notification, err := nc.NotificationRepo.FindByID(principal.UserID, parsedID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	if userIDFromToken != notification.UserID {
+	if principal.UserID != notification.UserID {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	err = nc.NotificationRepo.MarkIDAsRead(userIDFromToken, parsedID)
+	err = nc.NotificationRepo.MarkIDAsRead(principal.UserID, parsedID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
@@ -146,13 +146,13 @@ func (nc *NotificationController) MarkNotificationAsRead(w http.ResponseWriter,
 
 // MarkAllNotificationsAsRead marks all notifications as read
 func (nc *NotificationController) MarkAllNotificationsAsRead(w http.ResponseWriter, r *http.Request) {
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	err = nc.NotificationRepo.MarkAllAsRead(userIDFromToken)
+	err = nc.NotificationRepo.MarkAllAsRead(principal.UserID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
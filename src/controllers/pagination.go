@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"net/http"
+	"project01/src/models"
+)
+
+// pageRequest reads the limit and cursor query parameters of a list endpoint
+// ordered by time
+func pageRequest(r *http.Request) (models.PageRequest, error) {
+	query := r.URL.Query()
+	return models.NewPageRequest(query.Get("limit"), query.Get("cursor"), models.SortByTime)
+}
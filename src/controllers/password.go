@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"project01/src/auth"
+	"project01/src/config"
+	"project01/src/mailer"
+	"project01/src/models"
+	"project01/src/repositories"
+	"project01/src/response"
+	"project01/src/throttle"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordController struct {
+	UserRepo          repositories.UserRepositoryInterface
+	SessionRepo       repositories.SessionRepositoryInterface
+	TokenRepo         repositories.PersonalAccessTokenRepositoryInterface
+	PasswordResetRepo repositories.PasswordResetRepositoryInterface
+	Mailer            mailer.Mailer
+	EmailLimiter      *throttle.Limiter
+	IPLimiter         *throttle.Limiter
+}
+
+func NewPasswordController(db *sql.DB) *PasswordController {
+	store := throttle.NewStore(db)
+
+	return &PasswordController{
+		UserRepo:          repositories.NewUserRepository(db),
+		SessionRepo:       repositories.NewSessionRepository(db),
+		TokenRepo:         repositories.NewPersonalAccessTokenRepository(db),
+		PasswordResetRepo: repositories.NewPasswordResetRepository(db),
+		Mailer:            mailer.New(),
+		EmailLimiter: &throttle.Limiter{
+			Store:     store,
+			Prefix:    "reset-email:",
+			Threshold: config.PasswordResetMaxRequests,
+			BaseDelay: config.PasswordResetWindow,
+			MaxDelay:  config.PasswordResetWindow,
+			Window:    config.PasswordResetWindow,
+		},
+		IPLimiter: &throttle.Limiter{
+			Store:     store,
+			Prefix:    "reset-ip:",
+			Threshold: config.PasswordResetIPMaxRequests,
+			BaseDelay: config.PasswordResetWindow,
+			MaxDelay:  config.PasswordResetWindow,
+			Window:    config.PasswordResetWindow,
+		},
+	}
+}
+
+// ChangePassword changes the password of the current user
+func (pc *PasswordController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	parsedUserID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if principal.UserID != parsedUserID {
+		response.ERROR(w, http.StatusForbidden, errors.New("unauthorized"))
+		return
+	}
+
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var change models.PasswordChange
+	if err = json.Unmarshal(requestBody, &change); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	currentPassword := change.CurrentPassword
+	if err = change.Prepare("change"); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	savedPassword, err := pc.UserRepo.FindPassword(parsedUserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(savedPassword), []byte(currentPassword)); err != nil {
+		response.ERROR(w, http.StatusForbidden, errors.New("current password is incorrect"))
+		return
+	}
+
+	if err = pc.UserRepo.UpdatePassword(parsedUserID, change.NewPassword); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = pc.SessionRepo.RevokeOthers(parsedUserID, principal.SessionID); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// ForgotPassword emails a password reset link. It responds the same way
+// whether or not the email belongs to an account, and answers 429 once the
+// email or the client IP asked too often
+func (pc *PasswordController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err = json.Unmarshal(requestBody, &body); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if body.Email == "" {
+		response.ERROR(w, http.StatusBadRequest, errors.New("email is required"))
+		return
+	}
+
+	if pc.throttled(w, strings.ToLower(strings.TrimSpace(body.Email)), clientIP(r)) {
+		return
+	}
+
+	user, err := pc.UserRepo.FindByEmail(body.Email)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.JSON(w, http.StatusAccepted, nil)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Known and unknown emails must take the same time to answer, so the
+	// reset is stored and mailed after responding.
+	go pc.sendPasswordReset(*user)
+
+	response.JSON(w, http.StatusAccepted, nil)
+}
+
+// sendPasswordReset creates a reset token for user and mails them the link
+// that uses it. Failures are only logged, as the request was already
+// answered.
+func (pc *PasswordController) sendPasswordReset(user models.User) {
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	reset := models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(config.PasswordResetTTL),
+	}
+	if err = pc.PasswordResetRepo.Create(&reset); err != nil {
+		log.Println(err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/password/reset?token=%s", config.AppURL, url.QueryEscape(token))
+	err = pc.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account.\n\n"+
+			"Use the link below within %s to choose a new one:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.", config.PasswordResetTTL, link),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// ResetPassword sets a new password using a reset token, signs the user out
+// everywhere and revokes their personal access tokens
+func (pc *PasswordController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var change models.PasswordChange
+	if err = json.Unmarshal(requestBody, &change); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = change.Prepare("reset"); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reset, err := pc.PasswordResetRepo.Consume(auth.HashToken(change.Token))
+	if err != nil {
+		switch err {
+		case repositories.ErrNotFound:
+			response.ERROR(w, http.StatusBadRequest, errors.New("invalid reset token"))
+		case repositories.ErrTokenExpired:
+			response.ERROR(w, http.StatusBadRequest, err)
+		default:
+			response.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	if err = pc.UserRepo.UpdatePassword(reset.UserID, change.NewPassword); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = pc.SessionRepo.RevokeAllForUser(reset.UserID); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = pc.TokenRepo.RevokeAllForUser(reset.UserID); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// throttled counts a reset request against the email and the client IP. It
+// answers 429 with a Retry-After header, and returns true, when either
+// already reached its limit within config.PasswordResetWindow.
+func (pc *PasswordController) throttled(w http.ResponseWriter, email, ip string) bool {
+	limits := []struct {
+		limiter *throttle.Limiter
+		id      string
+	}{
+		{pc.EmailLimiter, email},
+		{pc.IPLimiter, ip},
+	}
+
+	for _, limit := range limits {
+		wait, err := limit.limiter.Check(limit.id)
+		if err != nil {
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return true
+		}
+
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			response.ERROR(w, http.StatusTooManyRequests, errors.New("too many password reset requests, try again later"))
+			return true
+		}
+	}
+
+	for _, limit := range limits {
+		if _, err := limit.limiter.Fail(limit.id); err != nil {
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"project01/src/auth"
+	"project01/src/models"
+	"project01/src/repositories"
+	"project01/src/response"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type PersonalAccessTokenController struct {
+	TokenRepo repositories.PersonalAccessTokenRepositoryInterface
+}
+
+func NewPersonalAccessTokenController(db *sql.DB) *PersonalAccessTokenController {
+	return &PersonalAccessTokenController{
+		TokenRepo: repositories.NewPersonalAccessTokenRepository(db),
+	}
+}
+
+// NewToken creates a personal access token. The token itself is only
+// returned once
+func (tc *PersonalAccessTokenController) NewToken(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var token models.PersonalAccessToken
+	if err = json.Unmarshal(requestBody, &token); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = token.Prepare(); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = auth.ValidateScopes(token.Scopes); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	secret, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	token.UserID = principal.UserID
+	token.Token = auth.PersonalAccessTokenPrefix + secret
+	token.TokenHash = auth.HashToken(token.Token)
+
+	if err = tc.TokenRepo.Create(&token); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, token)
+}
+
+// FindAllTokens returns the personal access tokens of the current user
+func (tc *PersonalAccessTokenController) FindAllTokens(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	tokens, err := tc.TokenRepo.FindAll(principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(tokens) == 0 {
+		response.JSON(w, http.StatusOK, []models.PersonalAccessToken{})
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tokens)
+}
+
+// RevokeToken revokes a personal access token
+func (tc *PersonalAccessTokenController) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	parsedID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = tc.TokenRepo.Revoke(principal.UserID, parsedID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
@@ -4,28 +4,43 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"project01/src/auth"
+	"project01/src/config"
 	"project01/src/models"
+	"project01/src/policy"
 	"project01/src/repositories"
 	"project01/src/response"
+	"project01/src/timeline"
 	"project01/src/websocket"
 	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
+const (
+	defaultThreadDepth = 3
+	maxThreadDepth     = 10
+	// maxEditNotifications bounds the notifications one edit sends.
+	maxEditNotifications = 1000
+)
+
 type PostController struct {
 	PostRepo         repositories.PostRepositoryInterface
+	UserRepo         repositories.UserRepositoryInterface
 	NotificationRepo repositories.NotificationRepositoryInterface
+	AuditLogRepo     repositories.AuditLogRepositoryInterface
 }
 
 func NewPostController(db *sql.DB) *PostController {
 	return &PostController{
 		PostRepo:         repositories.NewPostRepository(db),
+		UserRepo:         repositories.NewUserRepository(db),
 		NotificationRepo: repositories.NewNotificationRepository(db),
+		AuditLogRepo:     repositories.NewAuditLogRepository(db),
 	}
 }
 
@@ -58,13 +73,13 @@ func (pc *PostController) NewPost(w http.ResponseWriter, r *http.Request) {
 		post.ParentID = &parsedpostID
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	post.AuthorID = userIDFromToken
+	post.AuthorID = principal.UserID
 
 	err = post.Prepare()
 	if err != nil {
@@ -72,31 +87,79 @@ func (pc *PostController) NewPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var parent *models.Post
+	if post.ParentID != nil {
+		if parent, err = pc.PostRepo.FindWithoutReplies(*post.ParentID, principal.UserID); err != nil {
+			if err == repositories.ErrNotFound {
+				response.ERROR(w, http.StatusNotFound, err)
+				return
+			}
+
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		post.LimitVisibility(parent.Visibility)
+	}
+
+	if post.QuoteID != nil {
+		quote, err := pc.PostRepo.FindWithoutReplies(*post.QuoteID, principal.UserID)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				response.ERROR(w, http.StatusNotFound, errors.New("quoted post not found"))
+				return
+			}
+
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if quote.Visibility != models.VisibilityPublic {
+			response.ERROR(w, http.StatusForbidden, errors.New("only public posts can be quoted"))
+			return
+		}
+	}
+
 	createdPost, err := pc.PostRepo.Create(&post)
 	if err != nil {
+		if err == repositories.ErrMediaUnavailable {
+			response.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	if createdPost.ParentID == nil {
+		timeline.PostCreated(createdPost.ID, createdPost.AuthorID)
+	}
+
+	if parent != nil {
+		pc.notifyReply(createdPost, parent)
+	}
+	pc.notifyMentions(createdPost, nil)
+
 	response.JSON(w, http.StatusCreated, createdPost)
 }
 
 // PostsFollowedUsers returns a list of posts from followed users
 func (pc *PostController) PostsFollowedUsers(w http.ResponseWriter, r *http.Request) {
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	posts, err := pc.PostRepo.PostsFollowedUsers(userIDFromToken)
+	page, err := pageRequest(r)
 	if err != nil {
-		response.ERROR(w, http.StatusInternalServerError, err)
+		response.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if len(posts) == 0 {
-		response.JSON(w, http.StatusOK, []int{})
+	posts, err := pc.PostRepo.PostsFollowedUsers(principal.UserID, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -114,13 +177,13 @@ func (pc *PostController) FindPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	post, err := pc.PostRepo.FindByID(parsedPostID, userIDFromToken)
+	post, err := pc.PostRepo.FindByID(parsedPostID, principal.UserID)
 	if err != nil {
 		if err == repositories.ErrNotFound {
 			response.ERROR(w, http.StatusNotFound, err)
@@ -158,13 +221,13 @@ func (pc *PostController) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	post, err := pc.PostRepo.FindByID(parsedPostID, userIDFromToken)
+	post, err := pc.PostRepo.FindByID(parsedPostID, principal.UserID)
 	if err != nil {
 		if err == repositories.ErrNotFound {
 			response.ERROR(w, http.StatusNotFound, err)
@@ -175,20 +238,40 @@ func (pc *PostController) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if userIDFromToken != post.AuthorID {
+	if !policy.Can(principal, policy.UpdatePost, &policy.Resource{OwnerID: post.AuthorID}) {
 		response.ERROR(w, http.StatusForbidden, errors.New("unauthorized"))
 		return
 	}
 
 	post.Content = updatedPost.Content
+	previousMentions := post.Mentions
+
+	err = post.Prepare()
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
 
-	post.Prepare()
-	err = pc.PostRepo.Update(post)
+	edited, err := pc.PostRepo.Update(post, config.PostEditWindow)
 	if err != nil {
+		if err == repositories.ErrEditWindowClosed {
+			response.ERROR(w, http.StatusForbidden, err)
+			return
+		}
+
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	if edited {
+		if post, err = pc.PostRepo.FindWithoutReplies(post.ID, principal.UserID); err != nil {
+			log.Println(err)
+		} else {
+			pc.notifyMentions(post, previousMentions)
+			go pc.notifyEdit(post)
+		}
+	}
+
 	response.JSON(w, http.StatusNoContent, nil)
 }
 
@@ -203,13 +286,13 @@ func (pc *PostController) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	post, err := pc.PostRepo.FindByID(parsedPostID, userIDFromToken)
+	post, err := pc.PostRepo.FindByID(parsedPostID, principal.UserID)
 	if err != nil {
 		if err == repositories.ErrNotFound {
 			response.ERROR(w, http.StatusNotFound, err)
@@ -220,17 +303,32 @@ func (pc *PostController) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if userIDFromToken != post.AuthorID {
+	resource := &policy.Resource{OwnerID: post.AuthorID}
+	if !policy.Can(principal, policy.DeletePost, resource) {
 		response.ERROR(w, http.StatusForbidden, errors.New("unauthorized"))
 		return
 	}
 
-	err = pc.PostRepo.Delete(post.ID)
+	privileged := policy.Privileged(principal, policy.DeletePost, resource)
+
+	deletedBy := "author"
+	if privileged {
+		deletedBy = "moderator"
+	}
+
+	err = pc.PostRepo.Delete(post.ID, deletedBy)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	if privileged {
+		recordAudit(pc.AuditLogRepo, r, principal, policy.DeletePost, "post", post.ID, map[string]string{
+			"author_id": strconv.FormatUint(post.AuthorID, 10),
+			"content":   post.Content,
+		})
+	}
+
 	response.JSON(w, http.StatusNoContent, nil)
 }
 
@@ -245,13 +343,19 @@ func (pc *PostController) UserPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	posts, err := pc.PostRepo.FindByAuthorID(parsedUserID, userIDFromToken)
+	page, err := pageRequest(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	posts, err := pc.PostRepo.FindByAuthorID(parsedUserID, principal.UserID, page)
 	if err != nil {
 		if err == repositories.ErrNotFound {
 			response.ERROR(w, http.StatusNotFound, err)
@@ -262,11 +366,6 @@ func (pc *PostController) UserPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(posts) == 0 {
-		response.JSON(w, http.StatusOK, []int{})
-		return
-	}
-
 	response.JSON(w, http.StatusOK, posts)
 }
 
@@ -281,13 +380,13 @@ func (pc *PostController) LikePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	post, err := pc.PostRepo.FindByID(parsedPostID, userIDFromToken)
+	post, err := pc.PostRepo.FindByID(parsedPostID, principal.UserID)
 	if err != nil {
 		if err == repositories.ErrNotFound {
 			response.ERROR(w, http.StatusNotFound, err)
@@ -298,17 +397,17 @@ func (pc *PostController) LikePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newLikeInserted, err := pc.PostRepo.LikePost(post.ID, userIDFromToken)
+	newLikeInserted, err := pc.PostRepo.LikePost(post.ID, principal.UserID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	if newLikeInserted && post.AuthorID != userIDFromToken {
+	if newLikeInserted && post.AuthorID != principal.UserID {
 		notification := models.Notification{
 			UserID:       post.AuthorID,
 			Type:         "like",
-			SourceUserID: userIDFromToken,
+			SourceUserID: principal.UserID,
 			SourcePostID: &post.ID,
 		}
 
@@ -334,13 +433,13 @@ func (pc *PostController) UnlikePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	post, err := pc.PostRepo.FindByID(parsedPostID, userIDFromToken)
+	post, err := pc.PostRepo.FindByID(parsedPostID, principal.UserID)
 	if err != nil {
 		if err == repositories.ErrNotFound {
 			response.ERROR(w, http.StatusNotFound, err)
@@ -351,7 +450,7 @@ func (pc *PostController) UnlikePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = pc.PostRepo.UnlikePost(post.ID, userIDFromToken)
+	err = pc.PostRepo.UnlikePost(post.ID, principal.UserID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
@@ -371,16 +470,436 @@ func (pc *PostController) LikesPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	likes, err := pc.PostRepo.LikesPost(parsedPostID)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
-		response.ERROR(w, http.StatusInternalServerError, err)
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if !pc.checkVisible(w, parsedPostID, principal.UserID) {
 		return
 	}
 
-	if len(likes) == 0 {
-		response.JSON(w, http.StatusOK, []int{})
+	page, err := pageRequest(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	likes, err := pc.PostRepo.LikesPost(parsedPostID, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	response.JSON(w, http.StatusOK, likes)
 }
+
+// RepostPost reposts a post to the user's followers
+func (pc *PostController) RepostPost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	parsedPostID, err := strconv.ParseUint(postID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	post, err := pc.PostRepo.FindByID(parsedPostID, principal.UserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if post.Visibility != models.VisibilityPublic {
+		response.ERROR(w, http.StatusForbidden, errors.New("only public posts can be reposted"))
+		return
+	}
+
+	repostID, newRepostInserted, err := pc.PostRepo.Repost(post.ID, principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if newRepostInserted {
+		timeline.Reposted(repostID, principal.UserID)
+	}
+
+	if newRepostInserted && post.AuthorID != principal.UserID {
+		notification := models.Notification{
+			UserID:       post.AuthorID,
+			Type:         "repost",
+			SourceUserID: principal.UserID,
+			SourcePostID: &post.ID,
+		}
+
+		err = pc.NotificationRepo.CreateOrUpdate(notification)
+		if err != nil {
+			log.Println(err)
+		}
+
+		websocket.SendNotification(post.AuthorID, notification)
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// UnrepostPost undoes a repost
+func (pc *PostController) UnrepostPost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	parsedPostID, err := strconv.ParseUint(postID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	post, err := pc.PostRepo.FindByID(parsedPostID, principal.UserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = pc.PostRepo.Unrepost(post.ID, principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// PostReposts returns a list of users who reposted a post
+func (pc *PostController) PostReposts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	parsedPostID, err := strconv.ParseUint(postID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if !pc.checkVisible(w, parsedPostID, principal.UserID) {
+		return
+	}
+
+	page, err := pageRequest(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reposts, err := pc.PostRepo.Reposts(parsedPostID, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, reposts)
+}
+
+// notifyMentions sends a mention notification to each user a post mentions,
+// except its author and users it already mentioned before an edit.
+func (pc *PostController) notifyMentions(post *models.Post, previous []models.Mention) {
+	notified := map[uint64]bool{post.AuthorID: true}
+	for _, mention := range previous {
+		notified[mention.UserID] = true
+	}
+
+	for _, mention := range post.Mentions {
+		if notified[mention.UserID] {
+			continue
+		}
+		notified[mention.UserID] = true
+
+		pc.notify(mention.UserID, "mention", post.AuthorID, post.ID)
+	}
+}
+
+// notifyReply sends a reply notification to the author of the post replied
+// to, and to the author of the conversation's top-level post, if they can
+// see the reply.
+func (pc *PostController) notifyReply(reply, parent *models.Post) {
+	recipients := map[uint64]uint64{parent.AuthorID: parent.ID}
+
+	if parent.RootID != nil {
+		root, err := pc.PostRepo.FindWithoutReplies(*parent.RootID, reply.AuthorID)
+		if err != nil && err != repositories.ErrNotFound {
+			log.Println(err)
+		}
+
+		if root != nil && root.AuthorID != parent.AuthorID {
+			recipients[root.AuthorID] = root.ID
+		}
+	}
+
+	for userID, postID := range recipients {
+		visible, err := pc.PostRepo.IsVisible(reply.ID, userID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if visible {
+			pc.notify(userID, "reply", reply.AuthorID, postID)
+		}
+	}
+}
+
+// notify sends a notification about a post to userID, unless userID is the
+// source user, blocked them, muted the post's conversation or cannot see the
+// post.
+func (pc *PostController) notify(userID uint64, notificationType string, sourceUserID, postID uint64) {
+	if userID == sourceUserID {
+		return
+	}
+
+	blocking, err := pc.UserRepo.IsBlocking(userID, sourceUserID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	muted, err := pc.PostRepo.IsConversationMuted(userID, postID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if blocking || muted {
+		return
+	}
+
+	visible, err := pc.PostRepo.IsVisible(postID, userID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if !visible {
+		return
+	}
+
+	notification := models.Notification{
+		UserID:       userID,
+		Type:         notificationType,
+		SourceUserID: sourceUserID,
+		SourcePostID: &postID,
+	}
+
+	if err = pc.NotificationRepo.CreateOrUpdate(notification); err != nil {
+		log.Println(err)
+	}
+
+	websocket.SendNotification(userID, notification)
+}
+
+// notifyEdit sends a post_edited notification to the users who interacted
+// with a post, so that nobody is left endorsing words they never saw.
+func (pc *PostController) notifyEdit(post *models.Post) {
+	userIDs, err := pc.PostRepo.Interactors(post.ID, maxEditNotifications)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		pc.notify(userID, "post_edited", post.AuthorID, post.ID)
+	}
+}
+
+// checkVisible responds with 404 and returns false unless the post exists and
+// userID can see it.
+func (pc *PostController) checkVisible(w http.ResponseWriter, postID, userID uint64) bool {
+	visible, err := pc.PostRepo.IsVisible(postID, userID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return false
+	}
+
+	if !visible {
+		response.ERROR(w, http.StatusNotFound, repositories.ErrNotFound)
+		return false
+	}
+
+	return true
+}
+
+// PostHistory returns every version of a post's content, the current one
+// first
+func (pc *PostController) PostHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	parsedPostID, err := strconv.ParseUint(postID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if !pc.checkVisible(w, parsedPostID, principal.UserID) {
+		return
+	}
+
+	history, err := pc.PostRepo.History(parsedPostID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, history)
+}
+
+// PostThread returns a post with the posts it answers and a page of the
+// replies below it, nested down to the "depth" query parameter
+func (pc *PostController) PostThread(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	parsedPostID, err := strconv.ParseUint(postID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	depth := defaultThreadDepth
+	if value := r.URL.Query().Get("depth"); value != "" {
+		depth, err = strconv.Atoi(value)
+		if err != nil || depth < 1 || depth > maxThreadDepth {
+			response.ERROR(w, http.StatusBadRequest, fmt.Errorf("depth must be between 1 and %d", maxThreadDepth))
+			return
+		}
+	}
+
+	page, err := pageRequest(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	post, err := pc.PostRepo.FindWithoutReplies(parsedPostID, principal.UserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	thread := models.Thread{Post: *post}
+
+	thread.Ancestors, err = pc.PostRepo.Ancestors(post.ID, principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	thread.Replies, err = pc.PostRepo.ReplyTree(post.ID, principal.UserID, depth, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, thread)
+}
+
+// MuteConversation stops notifications from the conversation of a post
+func (pc *PostController) MuteConversation(w http.ResponseWriter, r *http.Request) {
+	pc.setConversationMuted(w, r, true)
+}
+
+// UnmuteConversation resumes notifications from the conversation of a post
+func (pc *PostController) UnmuteConversation(w http.ResponseWriter, r *http.Request) {
+	pc.setConversationMuted(w, r, false)
+}
+
+func (pc *PostController) setConversationMuted(w http.ResponseWriter, r *http.Request, muted bool) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	parsedPostID, err := strconv.ParseUint(postID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	post, err := pc.PostRepo.FindWithoutReplies(parsedPostID, principal.UserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if muted {
+		err = pc.PostRepo.MuteConversation(principal.UserID, post.ID)
+	} else {
+		err = pc.PostRepo.UnmuteConversation(principal.UserID, post.ID)
+	}
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
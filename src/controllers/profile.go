@@ -3,10 +3,13 @@ package controllers
 import (
 	"database/sql"
 	"net/http"
+	"net/url"
 	"project01/src/auth"
 	"project01/src/models"
 	"project01/src/repositories"
 	"project01/src/response"
+
+	"github.com/gorilla/mux"
 )
 
 type ProfileController struct {
@@ -21,40 +24,41 @@ func NewProfileController(db *sql.DB) *ProfileController {
 	}
 }
 
+// GetProfile returns the profile of the current user
 func (pc *ProfileController) GetProfile(w http.ResponseWriter, r *http.Request) {
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
+	page, err := pageRequest(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	type Result struct {
 		Profile *models.Profile
 		Err     error
 	}
 
-	resultChan := make(chan Result, 4)
+	resultChan := make(chan Result, 2)
 
 	go func() {
-		user, err := pc.UserRepo.FindByID(userIDFromToken)
-		resultChan <- Result{Profile: &models.Profile{User: *user}, Err: err}
+		user, err := pc.UserRepo.FindByID(principal.UserID)
+		if err != nil {
+			resultChan <- Result{Err: err}
+			return
+		}
+		resultChan <- Result{Profile: &models.Profile{User: *user}}
 	}()
 
 	go func() {
-		posts, err := pc.PostRepo.FindByAuthorID(userIDFromToken, userIDFromToken)
+		posts, err := pc.PostRepo.FindByAuthorID(principal.UserID, principal.UserID, page)
 		resultChan <- Result{Profile: &models.Profile{Posts: posts}, Err: err}
 	}()
 
-	go func() {
-		followers, err := pc.UserRepo.Followers(userIDFromToken)
-		resultChan <- Result{Profile: &models.Profile{FollowersCount: len(followers)}, Err: err}
-	}()
-
-	go func() {
-		following, err := pc.UserRepo.Following(userIDFromToken)
-		resultChan <- Result{Profile: &models.Profile{FollowingCount: len(following)}, Err: err}
-	}()
-
 	profile := &models.Profile{}
 
 	for i := 0; i < cap(resultChan); i++ {
@@ -68,16 +72,93 @@ func (pc *ProfileController) GetProfile(w http.ResponseWriter, r *http.Request)
 		if result.Profile.User != userBlank {
 			profile.User = result.Profile.User
 		}
-		if result.Profile.Posts != nil {
+		if result.Profile.Posts.Data != nil {
 			profile.Posts = result.Profile.Posts
 		}
-		if result.Profile.FollowersCount != 0 {
-			profile.FollowersCount = result.Profile.FollowersCount
+	}
+
+	profile.FollowersCount = profile.User.FollowerCount
+	profile.FollowingCount = profile.User.FollowingCount
+	profile.PostsCount = profile.User.PostCount
+
+	response.JSON(w, http.StatusOK, profile)
+}
+
+// GetUserProfile returns the profile of any user by username, along with how
+// the caller is connected to them
+func (pc *ProfileController) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	page, err := pageRequest(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+
+	user, err := pc.UserRepo.FindByUsername(username)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			pc.redirectRenamed(w, r, username)
+			return
 		}
-		if result.Profile.FollowingCount != 0 {
-			profile.FollowingCount = result.Profile.FollowingCount
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	posts, err := pc.PostRepo.FindByAuthorID(user.ID, principal.UserID, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	profile := &models.Profile{
+		User:           *user,
+		Posts:          posts,
+		FollowersCount: user.FollowerCount,
+		FollowingCount: user.FollowingCount,
+		PostsCount:     user.PostCount,
+	}
+
+	if user.ID != principal.UserID {
+		profile.User = user.Public()
+
+		profile.Relationship, err = pc.UserRepo.Relationship(principal.UserID, user.ID)
+		if err != nil {
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
 		}
 	}
 
 	response.JSON(w, http.StatusOK, profile)
 }
+
+// redirectRenamed sends requests for a recently changed username to the
+// profile under the user's current username
+func (pc *ProfileController) redirectRenamed(w http.ResponseWriter, r *http.Request, username string) {
+	current, err := pc.UserRepo.FindRenamed(username)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	location := "/users/@" + url.PathEscape(current) + "/profile"
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+
+	// The old name can be claimed again once the redirect expires, so the
+	// redirect must not be cached as permanent.
+	http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/auth"
+	"project01/src/config"
+	"project01/src/models"
+	"project01/src/repositories"
+	"project01/src/response"
+
+	"github.com/gorilla/mux"
+)
+
+type TagController struct {
+	TagRepo repositories.TagRepositoryInterface
+}
+
+func NewTagController(db *sql.DB) *TagController {
+	return &TagController{
+		TagRepo: repositories.NewTagRepository(db),
+	}
+}
+
+// TagPosts returns a list of posts tagged with a hashtag
+func (tc *TagController) TagPosts(w http.ResponseWriter, r *http.Request) {
+	tag, err := models.NormalizeTag(mux.Vars(r)["tag"])
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	page, err := pageRequest(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	posts, err := tc.TagRepo.FindPosts(tag, principal.UserID, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, posts)
+}
+
+// FollowTag adds the posts tagged with a hashtag to the user's home timeline
+func (tc *TagController) FollowTag(w http.ResponseWriter, r *http.Request) {
+	tag, err := models.NormalizeTag(mux.Vars(r)["tag"])
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err = tc.TagRepo.Follow(principal.UserID, tag); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// UnfollowTag stops following a hashtag
+func (tc *TagController) UnfollowTag(w http.ResponseWriter, r *http.Request) {
+	tag, err := models.NormalizeTag(mux.Vars(r)["tag"])
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err = tc.TagRepo.Unfollow(principal.UserID, tag); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// FollowedTags returns the hashtags the user follows
+func (tc *TagController) FollowedTags(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	tags, err := tc.TagRepo.FollowedTags(principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tags)
+}
+
+// Trends returns the hashtags trending over the last config.TrendsWindow
+func (tc *TagController) Trends(w http.ResponseWriter, r *http.Request) {
+	trends, err := tc.TagRepo.Trends(config.TrendsWindow, config.TrendsHalfLife, config.TrendsLimit)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, trends)
+}
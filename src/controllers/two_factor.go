@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"project01/src/auth"
+	"project01/src/models"
+	"project01/src/repositories"
+	"project01/src/response"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recoveryCodeCount = 10
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+type TwoFactorController struct {
+	UserRepo      repositories.UserRepositoryInterface
+	TwoFactorRepo repositories.TwoFactorRepositoryInterface
+}
+
+func NewTwoFactorController(db *sql.DB) *TwoFactorController {
+	return &TwoFactorController{
+		UserRepo:      repositories.NewUserRepository(db),
+		TwoFactorRepo: repositories.NewTwoFactorRepository(db),
+	}
+}
+
+// EnrollTwoFactor generates a new TOTP secret that must be confirmed before
+// it is enabled
+func (tc *TwoFactorController) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	user, err := tc.UserRepo.FindByID(principal.UserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tc.TwoFactorRepo.SetPendingSecret(user.ID, secret)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusConflict, errors.New("two-factor authentication is already enabled"))
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, models.TwoFactorEnrollment{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(secret, user.Email),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// their authenticator produces valid codes, and returns the recovery codes
+func (tc *TwoFactorController) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	body, err := readTwoFactorCode(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	twoFactor, err := tc.TwoFactorRepo.Find(principal.UserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusConflict, errors.New("two-factor enrollment not started"))
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if twoFactor.Enabled() {
+		response.ERROR(w, http.StatusConflict, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	counter, ok := auth.ValidateTOTP(twoFactor.Secret, body.Code, time.Now())
+	if !ok {
+		response.ERROR(w, http.StatusUnprocessableEntity, errInvalidTwoFactorCode)
+		return
+	}
+
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashToken(code)
+	}
+
+	if err = tc.TwoFactorRepo.Enable(principal.UserID, counter, hashes); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	})
+}
+
+// DisableTwoFactor turns two-factor authentication off. It requires both the
+// password and a current code
+func (tc *TwoFactorController) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	body, err := readTwoFactorCode(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	password, err := tc.UserRepo.FindPassword(principal.UserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(password), []byte(body.Password)); err != nil {
+		response.ERROR(w, http.StatusForbidden, errors.New("password is incorrect"))
+		return
+	}
+
+	twoFactor, err := tc.TwoFactorRepo.Find(principal.UserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusConflict, errors.New("two-factor authentication is not enabled"))
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !twoFactor.Enabled() {
+		response.ERROR(w, http.StatusConflict, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	valid, err := verifyTOTP(tc.TwoFactorRepo, twoFactor, body.Code)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !valid {
+		response.ERROR(w, http.StatusForbidden, errInvalidTwoFactorCode)
+		return
+	}
+
+	if err = tc.TwoFactorRepo.Disable(principal.UserID); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+func readTwoFactorCode(r *http.Request) (*models.TwoFactorCode, error) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var body models.TwoFactorCode
+	if err = json.Unmarshal(requestBody, &body); err != nil {
+		return nil, err
+	}
+
+	return &body, nil
+}
+
+// verifyTOTP checks a code and burns its time step so it can't be replayed.
+func verifyTOTP(twoFactorRepo repositories.TwoFactorRepositoryInterface, twoFactor *models.TwoFactor, code string) (bool, error) {
+	counter, ok := auth.ValidateTOTP(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return twoFactorRepo.UseCounter(twoFactor.UserID, counter)
+}
@@ -4,28 +4,45 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"project01/src/auth"
+	"project01/src/config"
+	"project01/src/mailer"
+	"project01/src/media"
 	"project01/src/models"
+	"project01/src/policy"
 	"project01/src/repositories"
 	"project01/src/response"
+	"project01/src/storage"
+	"project01/src/timeline"
 	"project01/src/websocket"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
 
+// autocompleteLimit is how many users AutocompleteUsers suggests.
+const autocompleteLimit = 10
+
 type UserController struct {
 	UserRepo         repositories.UserRepositoryInterface
 	NotificationRepo repositories.NotificationRepositoryInterface
+	AuditLogRepo     repositories.AuditLogRepositoryInterface
+	Mailer           mailer.Mailer
+	Storage          storage.Storage
 }
 
 func NewUserController(db *sql.DB) *UserController {
 	return &UserController{
 		UserRepo:         repositories.NewUserRepository(db),
 		NotificationRepo: repositories.NewNotificationRepository(db),
+		AuditLogRepo:     repositories.NewAuditLogRepository(db),
+		Mailer:           mailer.New(),
+		Storage:          storage.New(),
 	}
 }
 
@@ -50,13 +67,22 @@ func (uc *UserController) NewUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := uc.UserRepo.Create(&user)
+	createdUser, err := uc.UserRepo.Create(&user)
 	if err != nil {
+		if writeConflict(w, err) {
+			return
+		}
+
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	response.JSON(w, http.StatusCreated, userID)
+	err = sendVerificationEmail(uc.UserRepo, uc.Mailer, createdUser.ID, createdUser.Email)
+	if err != nil {
+		log.Println(err)
+	}
+
+	response.JSON(w, http.StatusCreated, createdUser)
 }
 
 // AllUsers returns all users
@@ -81,6 +107,12 @@ func (uc *UserController) FindUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
 	user, err := uc.UserRepo.FindByID(parsedUserID)
 	if err != nil {
 		if err == repositories.ErrNotFound {
@@ -92,6 +124,10 @@ func (uc *UserController) FindUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.ID != principal.UserID {
+		*user = user.Public()
+	}
+
 	response.JSON(w, http.StatusOK, user)
 }
 
@@ -119,14 +155,25 @@ func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	if userIDFromToken != parsedUserID {
-		response.ERROR(w, http.StatusUnauthorized, errors.New("unauthorized"))
+	if !policy.Can(principal, policy.UpdateUser, &policy.Resource{OwnerID: parsedUserID}) {
+		response.ERROR(w, http.StatusForbidden, errors.New("unauthorized"))
+		return
+	}
+
+	currentUser, err := uc.UserRepo.FindByID(parsedUserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -139,13 +186,102 @@ func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if writeConflict(w, err) {
+			return
+		}
+
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	if user.Email != currentUser.Email {
+		err = sendVerificationEmail(uc.UserRepo, uc.Mailer, user.ID, user.Email)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
 	response.JSON(w, http.StatusNoContent, nil)
 }
 
+// PatchUser changes only the fields present in the request body
+func (uc *UserController) PatchUser(w http.ResponseWriter, r *http.Request) {
+	parsedUserID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if !policy.Can(principal, policy.UpdateUser, &policy.Resource{OwnerID: parsedUserID}) {
+		response.ERROR(w, http.StatusForbidden, errors.New("unauthorized"))
+		return
+	}
+
+	responseBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var update models.UserUpdate
+	if err = json.Unmarshal(responseBody, &update); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = update.Prepare(); err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	currentUser, err := uc.UserRepo.FindByID(parsedUserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = uc.UserRepo.UpdateProfile(parsedUserID, &update, config.UsernameChangeCooldown, config.UsernameRedirectTTL)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		if writeConflict(w, err) {
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	user, err := uc.UserRepo.FindByID(parsedUserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.Email != currentUser.Email {
+		err = sendVerificationEmail(uc.UserRepo, uc.Mailer, user.ID, user.Email)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	response.JSON(w, http.StatusOK, user)
+}
+
 // DeleteUser deletes a user
 func (uc *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -157,14 +293,15 @@ func (uc *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	if userIDFromToken != parsedUserID {
-		response.ERROR(w, http.StatusUnauthorized, errors.New("unauthorized"))
+	resource := &policy.Resource{OwnerID: parsedUserID}
+	if !policy.Can(principal, policy.DeleteUser, resource) {
+		response.ERROR(w, http.StatusForbidden, errors.New("unauthorized"))
 		return
 	}
 
@@ -179,6 +316,10 @@ func (uc *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if policy.Privileged(principal, policy.DeleteUser, resource) {
+		recordAudit(uc.AuditLogRepo, r, principal, policy.DeleteUser, "user", parsedUserID, nil)
+	}
+
 	response.JSON(w, http.StatusNoContent, nil)
 }
 
@@ -187,22 +328,35 @@ func (uc *UserController) FindByFilters(w http.ResponseWriter, r *http.Request)
 	params := r.URL.Query()
 	term := params.Get("term")
 
+	page, err := models.NewPageRequest(params.Get("limit"), params.Get("cursor"), models.SortByText)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	if term == "" {
-		response.JSON(w, http.StatusOK, []int{})
+		response.JSON(w, http.StatusOK, models.Page[models.User]{Data: []models.User{}})
 		return
 	}
 
-	users, err := uc.UserRepo.FindByFilters(term)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
-		response.ERROR(w, http.StatusInternalServerError, err)
+		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	if len(users) == 0 {
-		response.JSON(w, http.StatusOK, []int{})
+	users, err := uc.UserRepo.FindByFilters(term, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	for i := range users.Data {
+		if users.Data[i].ID != principal.UserID {
+			users.Data[i] = users.Data[i].Public()
+		}
+	}
+
 	response.JSON(w, http.StatusOK, users)
 }
 
@@ -217,13 +371,13 @@ func (uc *UserController) FollowUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	if userIDFromToken == parsedUserID {
+	if principal.UserID == parsedUserID {
 		response.ERROR(w, http.StatusBadRequest, errors.New("you can't follow yourself"))
 		return
 	}
@@ -239,17 +393,32 @@ func (uc *UserController) FollowUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newFollowerInserted, err := uc.UserRepo.Follow(userIDFromToken, user.ID)
+	for _, pair := range [][2]uint64{{user.ID, principal.UserID}, {principal.UserID, user.ID}} {
+		blocking, err := uc.UserRepo.IsBlocking(pair[0], pair[1])
+		if err != nil {
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if blocking {
+			response.ERROR(w, http.StatusForbidden, errors.New("you can't follow this user"))
+			return
+		}
+	}
+
+	newFollowerInserted, err := uc.UserRepo.Follow(principal.UserID, user.ID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	if newFollowerInserted {
+		timeline.Followed(principal.UserID, user.ID)
+
 		notification := models.Notification{
 			UserID:       user.ID,
 			Type:         "new_follower",
-			SourceUserID: userIDFromToken,
+			SourceUserID: principal.UserID,
 		}
 
 		err = uc.NotificationRepo.CreateOrUpdate(notification)
@@ -274,13 +443,13 @@ func (uc *UserController) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDFromToken, err := auth.ExtractUserID(r)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		response.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	if userIDFromToken == parsedUserID {
+	if principal.UserID == parsedUserID {
 		response.ERROR(w, http.StatusBadRequest, errors.New("you can't unfollow yourself"))
 		return
 	}
@@ -296,12 +465,14 @@ func (uc *UserController) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = uc.UserRepo.Unfollow(userIDFromToken, user.ID)
+	err = uc.UserRepo.Unfollow(principal.UserID, user.ID)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	timeline.Unfollowed(principal.UserID, user.ID)
+
 	response.JSON(w, http.StatusNoContent, nil)
 }
 
@@ -316,14 +487,15 @@ func (uc *UserController) UserFollowers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	users, err := uc.UserRepo.Followers(parsedUserID)
+	page, err := pageRequest(r)
 	if err != nil {
-		response.ERROR(w, http.StatusInternalServerError, err)
+		response.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if len(users) == 0 {
-		response.JSON(w, http.StatusOK, []int{})
+	users, err := uc.UserRepo.Followers(parsedUserID, page)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -341,16 +513,300 @@ func (uc *UserController) UserFollowing(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	users, err := uc.UserRepo.Following(parsedUserID)
+	page, err := pageRequest(r)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	users, err := uc.UserRepo.Following(parsedUserID, page)
 	if err != nil {
 		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	if len(users) == 0 {
-		response.JSON(w, http.StatusOK, []int{})
+	response.JSON(w, http.StatusOK, users)
+}
+
+// BlockUser blocks a user, which also removes the follows between them
+func (uc *UserController) BlockUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	parsedUserID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if principal.UserID == parsedUserID {
+		response.ERROR(w, http.StatusBadRequest, errors.New("you can't block yourself"))
+		return
+	}
+
+	user, err := uc.UserRepo.FindByID(parsedUserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = uc.UserRepo.Block(principal.UserID, user.ID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	timeline.Unfollowed(principal.UserID, user.ID)
+	timeline.Unfollowed(user.ID, principal.UserID)
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// UnblockUser unblocks a user
+func (uc *UserController) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	parsedUserID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	err = uc.UserRepo.Unblock(principal.UserID, parsedUserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// AutocompleteUsers returns the users whose username or name starts with the
+// "prefix" query parameter, for completing mentions
+func (uc *UserController) AutocompleteUsers(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("prefix")), "@")
+	if prefix == "" {
+		response.ERROR(w, http.StatusBadRequest, errors.New("prefix is required"))
+		return
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	users, err := uc.UserRepo.Autocomplete(prefix, principal.UserID, autocompleteLimit)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	response.JSON(w, http.StatusOK, users)
 }
+
+// UploadAvatar replaces the avatar of a user with an uploaded image
+func (uc *UserController) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	uc.uploadImage(w, r, "avatar", media.AvatarSpec)
+}
+
+// DeleteAvatar removes the avatar of a user
+func (uc *UserController) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	uc.removeImage(w, r, "avatar")
+}
+
+// UploadBanner replaces the banner of a user with an uploaded image
+func (uc *UserController) UploadBanner(w http.ResponseWriter, r *http.Request) {
+	uc.uploadImage(w, r, "banner", media.BannerSpec)
+}
+
+// DeleteBanner removes the banner of a user
+func (uc *UserController) DeleteBanner(w http.ResponseWriter, r *http.Request) {
+	uc.removeImage(w, r, "banner")
+}
+
+// uploadImage processes the image in the "image" field of a multipart form,
+// stores it and sets it as the avatar or banner of the user, deleting the
+// file it replaces
+func (uc *UserController) uploadImage(w http.ResponseWriter, r *http.Request, kind string, spec media.ImageSpec) {
+	parsedUserID, ok := uc.authorizeImageChange(w, r)
+	if !ok {
+		return
+	}
+
+	// Leave room for the multipart headers around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.ImageMaxBytes)+64<<10)
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.ERROR(w, http.StatusRequestEntityTooLarge, fmt.Errorf("image must be at most %d bytes", config.ImageMaxBytes))
+			return
+		}
+
+		response.ERROR(w, http.StatusBadRequest, errors.New("image is required"))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(config.ImageMaxBytes)+1))
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(data) > config.ImageMaxBytes {
+		response.ERROR(w, http.StatusRequestEntityTooLarge, fmt.Errorf("image must be at most %d bytes", config.ImageMaxBytes))
+		return
+	}
+
+	processed, err := media.ProcessImage(data, spec)
+	if err != nil {
+		switch err {
+		case media.ErrUnsupportedImage:
+			response.ERROR(w, http.StatusUnsupportedMediaType, err)
+		case media.ErrImageTooLarge:
+			response.ERROR(w, http.StatusUnprocessableEntity, err)
+		default:
+			response.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	key, err := media.NewImageKey(kind, parsedUserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = uc.Storage.Put(r.Context(), key, "image/jpeg", processed); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	oldKey, err := uc.UserRepo.UpdateImage(parsedUserID, kind, uc.Storage.URL(key), key)
+	if err != nil {
+		if deleteErr := uc.Storage.Delete(r.Context(), key); deleteErr != nil {
+			log.Println(deleteErr)
+		}
+
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	uc.deleteStoredImage(r, oldKey)
+
+	user, err := uc.UserRepo.FindByID(parsedUserID)
+	if err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, user)
+}
+
+// removeImage clears the avatar or banner of the user and deletes the
+// uploaded file
+func (uc *UserController) removeImage(w http.ResponseWriter, r *http.Request, kind string) {
+	parsedUserID, ok := uc.authorizeImageChange(w, r)
+	if !ok {
+		return
+	}
+
+	oldKey, err := uc.UserRepo.UpdateImage(parsedUserID, kind, "", "")
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	uc.deleteStoredImage(r, oldKey)
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// authorizeImageChange parses the user ID from the path and checks that the
+// principal may update that user, answering the request when not
+func (uc *UserController) authorizeImageChange(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	parsedUserID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return 0, false
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return 0, false
+	}
+
+	if !policy.Can(principal, policy.UpdateUser, &policy.Resource{OwnerID: parsedUserID}) {
+		response.ERROR(w, http.StatusForbidden, errors.New("unauthorized"))
+		return 0, false
+	}
+
+	return parsedUserID, true
+}
+
+// deleteStoredImage deletes a replaced image. A failure only leaves the file
+// to the media garbage collector, so it is logged rather than returned
+func (uc *UserController) deleteStoredImage(r *http.Request, key string) {
+	if key == "" {
+		return
+	}
+
+	if err := uc.Storage.Delete(r.Context(), key); err != nil {
+		log.Println(err)
+	}
+}
+
+// writeConflict answers an error about a field that cannot take the requested
+// value with 409 and the name of the field. It reports whether it did
+func writeConflict(w http.ResponseWriter, err error) bool {
+	var field string
+	switch err {
+	case repositories.ErrUsernameTaken, repositories.ErrUsernameCooldown:
+		field = "username"
+	case repositories.ErrEmailTaken:
+		field = "email"
+	default:
+		return false
+	}
+
+	response.JSON(w, http.StatusConflict, struct {
+		Error string `json:"error"`
+		Field string `json:"field"`
+	}{
+		Error: err.Error(),
+		Field: field,
+	})
+	return true
+}
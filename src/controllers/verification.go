@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"project01/src/auth"
+	"project01/src/config"
+	"project01/src/mailer"
+	"project01/src/repositories"
+	"project01/src/response"
+	"strconv"
+	"time"
+)
+
+type VerificationController struct {
+	UserRepo repositories.UserRepositoryInterface
+	Mailer   mailer.Mailer
+}
+
+func NewVerificationController(db *sql.DB) *VerificationController {
+	return &VerificationController{
+		UserRepo: repositories.NewUserRepository(db),
+		Mailer:   mailer.New(),
+	}
+}
+
+// VerifyEmail marks the email address in a verification link as verified
+func (vc *VerificationController) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.ERROR(w, http.StatusBadRequest, errors.New("token is required"))
+		return
+	}
+
+	userID, email, err := auth.ParseVerificationToken(token)
+	if err != nil {
+		response.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = vc.UserRepo.MarkEmailVerified(userID, email)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusBadRequest, auth.ErrInvalidVerificationToken)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, nil)
+}
+
+// ResendVerification sends a new verification link to the current user,
+// at most once per config.VerificationResendInterval
+func (vc *VerificationController) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		response.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	user, err := vc.UserRepo.FindByID(principal.UserID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			response.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		response.ERROR(w, http.StatusConflict, errors.New("email already verified"))
+		return
+	}
+
+	if user.VerificationSentAt != nil {
+		wait := time.Until(user.VerificationSentAt.Add(config.VerificationResendInterval))
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			response.ERROR(w, http.StatusTooManyRequests, errors.New("verification email sent recently, try again later"))
+			return
+		}
+	}
+
+	if err = sendVerificationEmail(vc.UserRepo, vc.Mailer, user.ID, user.Email); err != nil {
+		response.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.JSON(w, http.StatusAccepted, nil)
+}
+
+// sendVerificationEmail emails a verification link for the given address and
+// records when it was sent.
+func sendVerificationEmail(userRepo repositories.UserRepositoryInterface, m mailer.Mailer, userID uint64, email string) error {
+	token := auth.CreateVerificationToken(userID, email)
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.AppURL, url.QueryEscape(token))
+
+	err := m.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm that this is your email address by opening the link below within %s:\n\n%s",
+			config.VerificationTTL, link),
+	})
+	if err != nil {
+		return err
+	}
+
+	return userRepo.MarkVerificationSent(userID)
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer writes emails to a file, or to the standard logger when Path is
+// empty, instead of delivering them.
+type LogMailer struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (m *LogMailer) Send(message Message) error {
+	entry := fmt.Sprintf("--- %s\nTo: %s\nSubject: %s\n\n%s\n",
+		time.Now().Format(time.RFC3339), message.To, message.Subject, message.Body)
+
+	if m.Path == "" {
+		log.Print(entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
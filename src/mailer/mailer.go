@@ -0,0 +1,31 @@
+package mailer
+
+import "project01/src/config"
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outgoing email.
+type Mailer interface {
+	Send(message Message) error
+}
+
+// New returns the mailer selected by config.MailDriver. Anything other than
+// "smtp" falls back to the log mailer, which is meant for local development.
+func New() Mailer {
+	if config.MailDriver == "smtp" {
+		return &SMTPMailer{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.MailFrom,
+		}
+	}
+
+	return &LogMailer{Path: config.MailLogPath}
+}
@@ -0,0 +1,223 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxAttachmentSide bounds the longer side of images attached to posts.
+	maxAttachmentSide = 2048
+	maxThumbnailSide  = 400
+	// blurhashSide is the size images are scaled down to before hashing,
+	// which keeps hashing cheap without changing the result noticeably.
+	blurhashSide = 32
+)
+
+var (
+	ErrUnsupportedMedia = errors.New("media must be a JPEG, PNG or GIF image or an MP4 video")
+	ErrInvalidVideo     = errors.New("video has no readable duration or dimensions")
+	ErrVideoTooLong     = errors.New("video is too long")
+)
+
+// Processed is an uploaded attachment ready to be stored.
+type Processed struct {
+	// Kind is "image", "gif" or "video".
+	Kind        string
+	ContentType string
+	Extension   string
+	Data        []byte
+	Width       int
+	Height      int
+	Duration    time.Duration
+	// Thumbnail and Blurhash are empty for videos, which cannot be decoded
+	// without an external tool.
+	Thumbnail []byte
+	Blurhash  string
+}
+
+// SniffMedia returns the kind of media data holds, judged by its content:
+// "image", "gif" or "video".
+func SniffMedia(data []byte) (string, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png":
+		return "image", nil
+	case "image/gif":
+		return "gif", nil
+	case "video/mp4":
+		return "video", nil
+	}
+
+	return "", ErrUnsupportedMedia
+}
+
+// ProcessAttachment validates an upload for a post. Images are turned
+// upright, stripped of metadata and scaled down to fit maxAttachmentSide.
+// GIFs are kept as uploaded so they stay animated. Videos are checked to be
+// MP4 files no longer than maxDuration and are stored as uploaded.
+func ProcessAttachment(data []byte, maxDuration time.Duration) (*Processed, error) {
+	kind, err := SniffMedia(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == "video" {
+		duration, width, height, err := mp4Info(data)
+		if err != nil {
+			return nil, err
+		}
+
+		if duration > maxDuration {
+			return nil, ErrVideoTooLong
+		}
+
+		return &Processed{
+			Kind:        kind,
+			ContentType: "video/mp4",
+			Extension:   "mp4",
+			Data:        data,
+			Width:       width,
+			Height:      height,
+			Duration:    duration,
+		}, nil
+	}
+
+	img, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	processed := &Processed{Kind: kind, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+
+	if kind == "gif" {
+		processed.ContentType, processed.Extension, processed.Data = "image/gif", "gif", data
+	} else {
+		width, height := fitWithin(processed.Width, processed.Height, maxAttachmentSide)
+		resized := img
+		if width != processed.Width || height != processed.Height {
+			resized = fill(img, width, height)
+		}
+
+		var out bytes.Buffer
+		if err = jpeg.Encode(&out, resized, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+
+		processed.ContentType, processed.Extension, processed.Data = "image/jpeg", "jpg", out.Bytes()
+		processed.Width, processed.Height = width, height
+	}
+
+	width, height := fitWithin(processed.Width, processed.Height, maxThumbnailSide)
+	var thumbnail bytes.Buffer
+	if err = jpeg.Encode(&thumbnail, fill(img, width, height), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	processed.Thumbnail = thumbnail.Bytes()
+
+	width, height = fitWithin(processed.Width, processed.Height, blurhashSide)
+	processed.Blurhash = blurhash(fill(img, width, height), 4, 3)
+
+	return processed, nil
+}
+
+// fitWithin scales width and height down, keeping their ratio, so that
+// neither is larger than side.
+func fitWithin(width, height, side int) (int, int) {
+	if width <= side && height <= side {
+		return width, height
+	}
+
+	if width >= height {
+		return side, max(height*side/width, 1)
+	}
+	return max(width*side/height, 1), side
+}
+
+// mp4Info reads the duration from the movie header and the dimensions of the
+// first visual track from the track headers of an MP4 file.
+func mp4Info(data []byte) (time.Duration, int, int, error) {
+	moov := findBox(data, "moov")
+	if moov == nil {
+		return 0, 0, 0, ErrInvalidVideo
+	}
+
+	var duration time.Duration
+	if mvhd := findBox(moov, "mvhd"); len(mvhd) >= 4 {
+		var timescale, units uint64
+		if mvhd[0] == 1 && len(mvhd) >= 32 {
+			timescale, units = uint64(binary.BigEndian.Uint32(mvhd[20:])), binary.BigEndian.Uint64(mvhd[24:])
+		} else if len(mvhd) >= 20 {
+			timescale, units = uint64(binary.BigEndian.Uint32(mvhd[12:])), uint64(binary.BigEndian.Uint32(mvhd[16:]))
+		}
+		if timescale > 0 {
+			duration = time.Duration(float64(units) / float64(timescale) * float64(time.Second))
+		}
+	}
+
+	for rest := moov; ; {
+		trak, next := nextBox(rest, "trak")
+		if trak == nil {
+			break
+		}
+		rest = next
+
+		tkhd := findBox(trak, "tkhd")
+		offset := 76
+		if len(tkhd) > 0 && tkhd[0] == 1 {
+			offset = 88
+		}
+		if len(tkhd) < offset+8 {
+			continue
+		}
+
+		// Dimensions are 16.16 fixed-point numbers.
+		width := int(binary.BigEndian.Uint32(tkhd[offset:]) >> 16)
+		height := int(binary.BigEndian.Uint32(tkhd[offset+4:]) >> 16)
+		if width > 0 && height > 0 && duration > 0 {
+			return duration, width, height, nil
+		}
+	}
+
+	return 0, 0, 0, ErrInvalidVideo
+}
+
+// findBox returns the content of the first box of the given type directly
+// inside data, or nil.
+func findBox(data []byte, boxType string) []byte {
+	content, _ := nextBox(data, boxType)
+	return content
+}
+
+// nextBox returns the content of the first box of the given type directly
+// inside data, and the data after that box.
+func nextBox(data []byte, boxType string) ([]byte, []byte) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		header := uint64(8)
+
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, nil
+			}
+			size, header = binary.BigEndian.Uint64(data[8:]), 16
+		}
+
+		if size < header || size > uint64(len(data)) {
+			return nil, nil
+		}
+
+		if string(data[4:8]) == boxType {
+			return data[header:size], data[size:]
+		}
+		data = data[size:]
+	}
+
+	return nil, nil
+}
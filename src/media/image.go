@@ -0,0 +1,239 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// maxPixels bounds the size of a decoded image, so that a small file that
+// declares huge dimensions cannot exhaust memory.
+const maxPixels = 25_000_000
+
+const jpegQuality = 85
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or GIF")
+	ErrImageTooLarge    = errors.New("image dimensions are too large")
+)
+
+// ImageSpec is the size an uploaded image is cropped and resized to.
+type ImageSpec struct {
+	Width  int
+	Height int
+}
+
+var (
+	AvatarSpec = ImageSpec{Width: 400, Height: 400}
+	BannerSpec = ImageSpec{Width: 1500, Height: 500}
+)
+
+// ProcessImage decodes an uploaded image, checking its type by its content
+// rather than by the name or type the client sent, and returns it as a JPEG
+// cropped and resized to spec. Re-encoding drops EXIF and any other
+// metadata, so the EXIF orientation is applied first.
+func ProcessImage(data []byte, spec ImageSpec) ([]byte, error) {
+	img, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err = jpeg.Encode(&out, fill(img, spec.Width, spec.Height), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// decode decodes an upright copy of a JPEG, PNG or GIF image, flattened onto
+// a white background.
+func decode(data []byte) (*image.RGBA, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	bounds := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+
+	return orient(flat, exifOrientation(data)), nil
+}
+
+// fill crops the largest centered region of src with the aspect ratio of
+// width and height and scales it to that size, averaging the source pixels
+// that fall into each destination pixel.
+func fill(src *image.RGBA, width, height int) *image.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+
+	cropWidth, cropHeight := srcWidth, srcWidth*height/width
+	if cropHeight > srcHeight {
+		cropWidth, cropHeight = srcHeight*width/height, srcHeight
+	}
+	cropWidth, cropHeight = max(cropWidth, 1), max(cropHeight, 1)
+	left, top := (srcWidth-cropWidth)/2, (srcHeight-cropHeight)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := top + y*cropHeight/height
+		y1 := max(top+(y+1)*cropHeight/height, y0+1)
+
+		for x := 0; x < width; x++ {
+			x0 := left + x*cropWidth/width
+			x1 := max(left+(x+1)*cropWidth/width, x0+1)
+
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					i += 4
+					n++
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = 0xff
+		}
+	}
+
+	return dst
+}
+
+// orient applies an EXIF orientation (1 to 8) so the image is upright.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			// Find the source pixel that ends up at (x, y).
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = width-1-x, y
+			case 3: // rotated 180°
+				sx, sy = width-1-x, height-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, height-1-y
+			case 5: // mirrored along the top-left diagonal
+				sx, sy = y, x
+			case 6: // needs a 90° clockwise rotation
+				sx, sy = y, height-1-x
+			case 7: // mirrored along the top-right diagonal
+				sx, sy = width-1-y, height-1-x
+			case 8: // needs a 90° counter-clockwise rotation
+				sx, sy = width-1-y, x
+			}
+
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
+
+// exifOrientation returns the orientation tag of a JPEG's EXIF metadata, or
+// 1 when there is none.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return 1
+		}
+
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			// The image data starts; metadata always comes before it.
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && len(segment) > 14 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+
+		i += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation reads the orientation tag (0x0112) from the first IFD of
+// the TIFF structure embedded in EXIF.
+func tiffOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < entries; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 1
+}
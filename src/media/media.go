@@ -0,0 +1,164 @@
+package media
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"project01/src/config"
+	"project01/src/repositories"
+	"project01/src/storage"
+	"time"
+)
+
+const attachmentPrefix = "attachments/"
+
+// imagePrefixes are the storage prefixes of user images, by kind.
+var imagePrefixes = map[string]string{
+	"avatar": "avatars/",
+	"banner": "banners/",
+}
+
+// minGarbageAge keeps files that were just stored from being collected, since
+// an upload stores its files before the row that points at them.
+const minGarbageAge = time.Hour
+
+// NewImageKey returns a new, unguessable storage key for an image of a user.
+// Every upload gets its own key, so clients and caches never see a stale
+// file under a URL.
+func NewImageKey(kind string, userID uint64) (string, error) {
+	prefix, ok := imagePrefixes[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown image kind %q", kind)
+	}
+
+	base, err := newKey(prefix, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return base + ".jpg", nil
+}
+
+// NewAttachmentKeys returns new storage keys for an attachment file with the
+// given extension and for its thumbnail.
+func NewAttachmentKeys(userID uint64, extension string) (string, string, error) {
+	base, err := newKey(attachmentPrefix, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base + "." + extension, base + "_thumb.jpg", nil
+}
+
+func newKey(prefix string, userID uint64) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%d/%s", prefix, userID, hex.EncodeToString(b)), nil
+}
+
+// StartCleanup collects garbage every config.MediaCleanupInterval. Running it
+// on several instances at once is harmless, as deletes are idempotent.
+func StartCleanup(db *sql.DB, store storage.Storage) {
+	go func() {
+		ticker := time.NewTicker(config.MediaCleanupInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deleted, err := CollectGarbage(context.Background(), db, store)
+			if err != nil {
+				log.Printf("media: %v", err)
+			}
+			if deleted > 0 {
+				log.Printf("media: deleted %d unused files", deleted)
+			}
+		}
+	}()
+}
+
+// CollectGarbage deletes attachments that were uploaded more than
+// config.MediaUnattachedTTL ago but never used in a post, then every stored
+// file nothing points at anymore, such as the images of deleted users or
+// posts. It returns how many files it deleted.
+func CollectGarbage(ctx context.Context, db *sql.DB, store storage.Storage) (int, error) {
+	userRepo := repositories.NewUserRepository(db)
+	mediaRepo := repositories.NewMediaRepository(db)
+	deleted := 0
+
+	keys, err := mediaRepo.DeleteUnattached(time.Now().Add(-config.MediaUnattachedTTL))
+	if err != nil {
+		return deleted, err
+	}
+
+	for _, key := range keys {
+		if err = store.Delete(ctx, key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	sweeps := map[string]func(keys []string) ([]string, error){
+		imagePrefixes["avatar"]: userRepo.FindImageKeys,
+		imagePrefixes["banner"]: userRepo.FindImageKeys,
+		attachmentPrefix:        mediaRepo.FindKeys,
+	}
+
+	for prefix, findUsed := range sweeps {
+		n, err := sweep(ctx, store, prefix, findUsed)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// sweep deletes the files under prefix that are older than minGarbageAge and
+// that findUsed does not report as used.
+func sweep(ctx context.Context, store storage.Storage, prefix string, findUsed func(keys []string) ([]string, error)) (int, error) {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	var candidates []string
+	for _, object := range objects {
+		if time.Since(object.ModifiedAt) >= minGarbageAge {
+			candidates = append(candidates, object.Key)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	used, err := findUsed(candidates)
+	if err != nil {
+		return 0, err
+	}
+
+	inUse := make(map[string]bool, len(used))
+	for _, key := range used {
+		inUse[key] = true
+	}
+
+	deleted := 0
+	for _, key := range candidates {
+		if inUse[key] {
+			continue
+		}
+
+		if err = store.Delete(ctx, key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
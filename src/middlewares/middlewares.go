@@ -1,18 +1,169 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
 	"project01/src/auth"
+	"project01/src/config"
+	"project01/src/policy"
+	"project01/src/repositories"
 	"project01/src/response"
+	"strconv"
+	"strings"
 )
 
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// AuthMiddleware verifies the bearer token once, rejects revoked sessions and
+// suspended users, and stores the resulting principal, with the user's
+// current role, in the request context. The bearer token is either a session
+// access token or a personal access token.
+func AuthMiddleware(sessionRepo repositories.SessionRepositoryInterface, tokenRepo repositories.PersonalAccessTokenRepositoryInterface, userRepo repositories.UserRepositoryInterface, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := auth.ValidateToken(r); err != nil {
+		var principal *auth.Principal
+		var status int
+		var err error
+
+		tokenString := auth.ExtractToken(r)
+		if strings.HasPrefix(tokenString, auth.PersonalAccessTokenPrefix) {
+			principal, status, err = authenticatePersonalAccessToken(tokenRepo, tokenString)
+		} else {
+			principal, status, err = authenticateSession(sessionRepo, r)
+		}
+		if err != nil {
+			response.ERROR(w, status, err)
+			return
+		}
+
+		user, err := userRepo.FindAccess(principal.UserID)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				response.ERROR(w, http.StatusUnauthorized, errors.New("invalid token"))
+				return
+			}
+
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if user.SuspendedAt != nil {
+			response.ERROR(w, http.StatusForbidden, auth.ErrAccountSuspended)
+			return
+		}
+		principal.Role = user.Role
+
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+func authenticateSession(sessionRepo repositories.SessionRepositoryInterface, r *http.Request) (*auth.Principal, int, error) {
+	principal, err := auth.ParseToken(r)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+
+	active, err := sessionRepo.IsActive(principal.SessionID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if !active {
+		return nil, http.StatusUnauthorized, repositories.ErrSessionRevoked
+	}
+
+	return principal, http.StatusOK, nil
+}
+
+func authenticatePersonalAccessToken(tokenRepo repositories.PersonalAccessTokenRepositoryInterface, tokenString string) (*auth.Principal, int, error) {
+	token, err := tokenRepo.Authenticate(auth.HashToken(tokenString))
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			return nil, http.StatusUnauthorized, errors.New("invalid token")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return &auth.Principal{
+		UserID:   token.UserID,
+		TokenID:  "pat:" + strconv.FormatUint(token.ID, 10),
+		Scopes:   token.Scopes,
+		IssuedAt: token.CreatedAt,
+	}, http.StatusOK, nil
+}
+
+// ScopeMiddleware rejects principals that were not granted scope. It must run
+// after AuthMiddleware.
+func ScopeMiddleware(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.PrincipalFromContext(r.Context())
+		if err != nil {
 			response.ERROR(w, http.StatusUnauthorized, err)
 			return
 		}
 
+		if !principal.HasScope(scope) {
+			if scope == "" {
+				response.ERROR(w, http.StatusForbidden, errors.New("this route requires a session token"))
+				return
+			}
+
+			response.ERROR(w, http.StatusForbidden, errors.New("token is missing the "+scope+" scope"))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// PermissionMiddleware rejects principals the policy does not allow to
+// perform action. It must run after AuthMiddleware.
+func PermissionMiddleware(action policy.Action, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.PrincipalFromContext(r.Context())
+		if err != nil {
+			response.ERROR(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		if !policy.Can(principal, action, nil) {
+			response.ERROR(w, http.StatusForbidden, errors.New("unauthorized"))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// VerifiedMiddleware enforces config.UnverifiedPolicy for users whose email
+// address has not been verified yet. It must run after AuthMiddleware.
+func VerifiedMiddleware(userRepo repositories.UserRepositoryInterface, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch config.UnverifiedPolicy {
+		case "full":
+			next(w, r)
+			return
+		case "read_only":
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next(w, r)
+				return
+			}
+		}
+
+		principal, err := auth.PrincipalFromContext(r.Context())
+		if err != nil {
+			response.ERROR(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		verified, err := userRepo.IsEmailVerified(principal.UserID)
+		if err != nil {
+			response.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !verified {
+			response.ERROR(w, http.StatusForbidden, errors.New("email address not verified"))
+			return
+		}
+
 		next(w, r)
 	}
 }
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS identities;
+DROP TABLE IF EXISTS oauth_states;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS login_throttles;
+DROP TABLE IF EXISTS personal_access_tokens;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS likes;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS followers;
+DROP TABLE IF EXISTS users;
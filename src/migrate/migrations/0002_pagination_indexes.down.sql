@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notifications_user_id_created_at;
+DROP INDEX IF EXISTS idx_likes_post_id_created_at;
+DROP INDEX IF EXISTS idx_followers_follower_id_created_at;
+DROP INDEX IF EXISTS idx_followers_user_id_created_at;
+DROP INDEX IF EXISTS idx_posts_author_id_created_at;
@@ -0,0 +1,5 @@
+CREATE INDEX idx_posts_author_id_created_at ON posts (author_id, created_at DESC, id DESC);
+CREATE INDEX idx_followers_user_id_created_at ON followers (user_id, created_at DESC, id DESC);
+CREATE INDEX idx_followers_follower_id_created_at ON followers (follower_id, created_at DESC, id DESC);
+CREATE INDEX idx_likes_post_id_created_at ON likes (post_id, created_at DESC, id DESC);
+CREATE INDEX idx_notifications_user_id_created_at ON notifications (user_id, created_at DESC, id DESC);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS timelines;
+
+ALTER TABLE users DROP COLUMN IF EXISTS fan_out_on_read;
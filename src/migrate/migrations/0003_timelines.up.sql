@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN fan_out_on_read BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE timelines (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    author_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX idx_timelines_user_id_created_at ON timelines (user_id, created_at DESC, post_id DESC);
+CREATE INDEX idx_timelines_user_id_author_id ON timelines (user_id, author_id);
+
+INSERT INTO timelines (user_id, post_id, author_id, created_at)
+SELECT followers.follower_id, posts.id, posts.author_id, posts.created_at
+FROM posts
+JOIN followers ON followers.user_id = posts.author_id
+WHERE posts.parent_id IS NULL AND posts.created_at IS NOT NULL;
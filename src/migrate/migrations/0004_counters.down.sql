@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN IF EXISTS post_count;
+ALTER TABLE users DROP COLUMN IF EXISTS following_count;
+ALTER TABLE users DROP COLUMN IF EXISTS follower_count;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS reply_count;
+ALTER TABLE posts DROP COLUMN IF EXISTS like_count;
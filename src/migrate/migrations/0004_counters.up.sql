@@ -0,0 +1,16 @@
+ALTER TABLE posts ADD COLUMN like_count INT NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN reply_count INT NOT NULL DEFAULT 0;
+
+ALTER TABLE users ADD COLUMN follower_count INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN following_count INT NOT NULL DEFAULT 0;
+-- post_count only counts top-level posts, like GET /users/{id}/posts.
+ALTER TABLE users ADD COLUMN post_count INT NOT NULL DEFAULT 0;
+
+UPDATE posts SET
+    like_count = (SELECT COUNT(*) FROM likes WHERE likes.post_id = posts.id),
+    reply_count = (SELECT COUNT(*) FROM posts AS replies WHERE replies.parent_id = posts.id);
+
+UPDATE users SET
+    follower_count = (SELECT COUNT(*) FROM followers WHERE followers.user_id = users.id),
+    following_count = (SELECT COUNT(*) FROM followers WHERE followers.follower_id = users.id),
+    post_count = (SELECT COUNT(*) FROM posts WHERE posts.author_id = users.id AND posts.parent_id IS NULL);
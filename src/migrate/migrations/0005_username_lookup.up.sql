@@ -0,0 +1 @@
+CREATE INDEX idx_users_lower_username ON users (LOWER(username));
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS username_history;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS username_changed_at,
+    ALTER COLUMN bio DROP NOT NULL,
+    ALTER COLUMN bio DROP DEFAULT,
+    ALTER COLUMN avatar_url DROP NOT NULL,
+    ALTER COLUMN avatar_url DROP DEFAULT;
@@ -0,0 +1,22 @@
+UPDATE users SET avatar_url = '' WHERE avatar_url IS NULL;
+UPDATE users SET bio = '' WHERE bio IS NULL;
+
+ALTER TABLE users
+    ALTER COLUMN avatar_url SET DEFAULT '',
+    ALTER COLUMN avatar_url SET NOT NULL,
+    ALTER COLUMN bio SET DEFAULT '',
+    ALTER COLUMN bio SET NOT NULL,
+    ADD COLUMN username_changed_at TIMESTAMP WITH TIME ZONE;
+
+-- Old usernames keep pointing at their user until expires_at, so links to a
+-- renamed profile redirect and nobody else can claim the name meanwhile.
+CREATE TABLE username_history (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    username VARCHAR(100) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_username_history_lower_username ON username_history (LOWER(username));
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS banner_url,
+    DROP COLUMN IF EXISTS avatar_key,
+    DROP COLUMN IF EXISTS banner_key;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN banner_url VARCHAR(200) NOT NULL DEFAULT '',
+    -- Storage keys of uploaded images. Empty when the URL points elsewhere.
+    ADD COLUMN avatar_key VARCHAR(200) NOT NULL DEFAULT '',
+    ADD COLUMN banner_key VARCHAR(200) NOT NULL DEFAULT '';
@@ -0,0 +1,25 @@
+CREATE TABLE media_attachments (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    -- NULL until a post references the upload.
+    post_id INT,
+    position SMALLINT NOT NULL DEFAULT 0,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('image', 'gif', 'video')),
+    content_type VARCHAR(50) NOT NULL,
+    url VARCHAR(500) NOT NULL,
+    key VARCHAR(200) NOT NULL,
+    thumbnail_url VARCHAR(500) NOT NULL DEFAULT '',
+    thumbnail_key VARCHAR(200) NOT NULL DEFAULT '',
+    width INT NOT NULL,
+    height INT NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    size BIGINT NOT NULL,
+    blurhash VARCHAR(100) NOT NULL DEFAULT '',
+    alt_text TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_media_attachments_post_id ON media_attachments (post_id, position);
+CREATE INDEX idx_media_attachments_unattached ON media_attachments (created_at) WHERE post_id IS NULL;
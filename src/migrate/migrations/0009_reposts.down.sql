@@ -0,0 +1,5 @@
+DELETE FROM timelines WHERE repost_id IS NOT NULL;
+ALTER TABLE timelines DROP COLUMN IF EXISTS repost_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS quote_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS repost_count;
+DROP TABLE IF EXISTS reposts;
@@ -0,0 +1,21 @@
+CREATE TABLE reposts (
+    id SERIAL PRIMARY KEY,
+    post_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (post_id, user_id)
+);
+
+CREATE INDEX idx_reposts_post_id_created_at ON reposts (post_id, created_at DESC, id DESC);
+CREATE INDEX idx_reposts_user_id_created_at ON reposts (user_id, created_at DESC, post_id DESC);
+
+ALTER TABLE posts ADD COLUMN repost_count INT NOT NULL DEFAULT 0;
+-- A quote post embeds the post it quotes. Deleting the quoted post keeps the
+-- quote.
+ALTER TABLE posts ADD COLUMN quote_id INT REFERENCES posts(id) ON DELETE SET NULL;
+
+-- Timeline rows added by a repost point at it, so undoing the repost removes
+-- them. author_id is then the reposter, so unfollowing them removes it too.
+ALTER TABLE timelines ADD COLUMN repost_id INT REFERENCES reposts(id) ON DELETE CASCADE;
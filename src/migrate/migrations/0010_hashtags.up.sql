@@ -0,0 +1,43 @@
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    -- Tags are stored lower-cased, see models.NormalizeTag.
+    name VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE post_tags (
+    post_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    -- created_at copies the post's, so tag pages and trends read one table.
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (post_id, tag_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_tags_tag_id_created_at ON post_tags (tag_id, created_at DESC, post_id DESC);
+CREATE INDEX idx_post_tags_created_at ON post_tags (created_at);
+
+CREATE TABLE tag_follows (
+    user_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, tag_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- Index the tags of existing posts.
+INSERT INTO tags (name)
+SELECT DISTINCT LOWER(match[1])
+FROM posts
+CROSS JOIN LATERAL regexp_matches(posts.content, '(?:^|[^[:alnum:]_])#([[:alnum:]_]*[[:alpha:]][[:alnum:]_]*)', 'g') AS match
+WHERE char_length(match[1]) <= 50
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO post_tags (post_id, tag_id, created_at)
+SELECT DISTINCT posts.id, tags.id, posts.created_at
+FROM posts
+CROSS JOIN LATERAL regexp_matches(posts.content, '(?:^|[^[:alnum:]_])#([[:alnum:]_]*[[:alpha:]][[:alnum:]_]*)', 'g') AS match
+JOIN tags ON tags.name = LOWER(match[1])
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS blocks;
+DROP TABLE IF EXISTS post_mentions;
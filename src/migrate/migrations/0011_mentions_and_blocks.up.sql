@@ -0,0 +1,23 @@
+CREATE TABLE post_mentions (
+    post_id INT NOT NULL,
+    user_id INT NOT NULL,
+    -- Offsets in characters into the post's content, end exclusive.
+    start_offset INT NOT NULL,
+    end_offset INT NOT NULL,
+    PRIMARY KEY (post_id, start_offset),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_mentions_user_id ON post_mentions (user_id, post_id);
+
+CREATE TABLE blocks (
+    blocker_id INT NOT NULL,
+    blocked_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_blocks_blocked_id ON blocks (blocked_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS conversation_mutes;
+DROP INDEX IF EXISTS idx_posts_parent_id_created_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS root_id;
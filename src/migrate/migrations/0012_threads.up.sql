@@ -0,0 +1,24 @@
+-- root_id is the top-level post of the conversation a reply belongs to, and
+-- NULL on top-level posts.
+ALTER TABLE posts ADD COLUMN root_id INT REFERENCES posts(id) ON DELETE CASCADE;
+
+WITH RECURSIVE roots AS (
+    SELECT id, id AS root_id FROM posts WHERE parent_id IS NULL
+    UNION ALL
+    SELECT posts.id, roots.root_id FROM posts JOIN roots ON posts.parent_id = roots.id
+)
+UPDATE posts SET root_id = roots.root_id
+FROM roots
+WHERE posts.id = roots.id AND posts.parent_id IS NOT NULL;
+
+CREATE INDEX idx_posts_root_id ON posts (root_id);
+CREATE INDEX idx_posts_parent_id_created_at ON posts (parent_id, created_at, id);
+
+CREATE TABLE conversation_mutes (
+    user_id INT NOT NULL,
+    root_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, root_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (root_id) REFERENCES posts(id) ON DELETE CASCADE
+);
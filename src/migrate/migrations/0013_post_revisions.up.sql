@@ -0,0 +1,14 @@
+-- A revision is a version of a post's content that an edit replaced.
+-- created_at is when that version was published.
+CREATE TABLE post_revisions (
+    id SERIAL PRIMARY KEY,
+    post_id INT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_revisions_post_id ON post_revisions (post_id, created_at DESC, id DESC);
+
+ALTER TABLE posts ADD COLUMN edited_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN revision_count INT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+-- Deleted posts stay as tombstones so that the replies below them survive.
+-- deleted_by tells an author's deletion from a moderator's removal.
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN deleted_by VARCHAR(10) CHECK (deleted_by IN ('author', 'moderator'));
+
+CREATE INDEX idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
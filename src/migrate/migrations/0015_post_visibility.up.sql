@@ -0,0 +1,5 @@
+-- public posts are visible to everyone, followers posts to the author's
+-- followers and direct posts to the users they mention. Authors always see
+-- their own posts.
+ALTER TABLE posts ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'direct'));
@@ -0,0 +1,8 @@
+-- IDs of two-factor challenge tokens that completed a login, so that each
+-- token signs in once. Rows are useless once the token expired.
+CREATE TABLE used_challenge_tokens (
+    token_id VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_used_challenge_tokens_expires_at ON used_challenge_tokens (expires_at);
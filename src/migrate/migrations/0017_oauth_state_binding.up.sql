@@ -0,0 +1,6 @@
+-- binding_hash is the hash of a random value kept in a cookie of the browser
+-- that started the authorization request. The callback must present it, so
+-- a request started by one person cannot be completed by another. Pending
+-- requests have no cookie and cannot complete anymore.
+DELETE FROM oauth_states;
+ALTER TABLE oauth_states ADD COLUMN binding_hash CHAR(64) NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE posts DROP CONSTRAINT posts_root_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_root_id_fkey
+    FOREIGN KEY (root_id) REFERENCES posts(id) ON DELETE CASCADE;
@@ -0,0 +1,6 @@
+-- Removing a conversation's top-level post must never take other users'
+-- replies with it. Replies keep their place under their parent and only lose
+-- the link to the root.
+ALTER TABLE posts DROP CONSTRAINT posts_root_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_root_id_fkey
+    FOREIGN KEY (root_id) REFERENCES posts(id) ON DELETE SET NULL;
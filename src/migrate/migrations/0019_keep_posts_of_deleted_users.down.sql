@@ -0,0 +1,17 @@
+-- The posts of deleted accounts have no author to give back, and removing
+-- them would cascade through other users' replies. Refuse instead.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM posts WHERE author_id IS NULL) THEN
+        RAISE EXCEPTION 'posts of deleted accounts exist; author_id cannot be made NOT NULL';
+    END IF;
+END $$;
+
+ALTER TABLE posts DROP CONSTRAINT posts_parent_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_parent_id_fkey
+    FOREIGN KEY (parent_id) REFERENCES posts(id) ON DELETE CASCADE;
+
+ALTER TABLE posts DROP CONSTRAINT posts_author_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_author_id_fkey
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE posts ALTER COLUMN author_id SET NOT NULL;
@@ -0,0 +1,12 @@
+-- Deleting an account turns its posts into tombstones instead of removing
+-- them, so that other users' replies below them survive. The posts lose
+-- their author when the account goes away, and a post with replies can no
+-- longer be removed at all.
+ALTER TABLE posts ALTER COLUMN author_id DROP NOT NULL;
+ALTER TABLE posts DROP CONSTRAINT posts_author_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_author_id_fkey
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE posts DROP CONSTRAINT posts_parent_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_parent_id_fkey
+    FOREIGN KEY (parent_id) REFERENCES posts(id) ON DELETE RESTRICT;
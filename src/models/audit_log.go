@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// AuditLog records a privileged action, i.e. one a user was allowed to take
+// because of their role rather than because they own the target.
+type AuditLog struct {
+	ID         uint64            `json:"id,omitempty"`
+	ActorID    uint64            `json:"actor_id,omitempty"`
+	Action     string            `json:"action,omitempty"`
+	TargetType string            `json:"target_type,omitempty"`
+	TargetID   uint64            `json:"target_id,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	IPAddress  string            `json:"ip_address,omitempty"`
+	CreatedAt  time.Time         `json:"created_at,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Identity links an account at an external OpenID Connect provider to a user.
+type Identity struct {
+	ID        uint64    `json:"id,omitempty"`
+	UserID    uint64    `json:"user_id,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	Subject   string    `json:"subject,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// OAuthState is a pending authorization request. LinkUserID is set when a
+// signed-in user is connecting a new identity rather than signing in.
+// BindingHash ties the request to the browser that started it.
+type OAuthState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	LinkUserID   *uint64
+	BindingHash  string
+	ExpiresAt    time.Time
+}
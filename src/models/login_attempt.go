@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type LoginAttempt struct {
+	ID        uint64    `json:"id,omitempty"`
+	UserID    *uint64   `json:"user_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxPostMedia is how many attachments a post can carry.
+const MaxPostMedia = 4
+
+const maxAltTextLength = 1500
+
+// MediaAttachment is an image, GIF or video uploaded for a post. It belongs
+// to its uploader until a post references it.
+type MediaAttachment struct {
+	ID           uint64    `json:"id"`
+	UserID       uint64    `json:"-"`
+	PostID       *uint64   `json:"post_id,omitempty"`
+	Kind         string    `json:"kind"`
+	ContentType  string    `json:"content_type"`
+	URL          string    `json:"url"`
+	Key          string    `json:"-"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	ThumbnailKey string    `json:"-"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	DurationMS   int64     `json:"duration_ms,omitempty"`
+	Size         int64     `json:"size"`
+	Blurhash     string    `json:"blurhash,omitempty"`
+	AltText      string    `json:"alt_text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PrepareAltText trims and validates alternative text for an attachment.
+func PrepareAltText(altText string) (string, error) {
+	altText = strings.TrimSpace(altText)
+
+	if utf8.RuneCountInString(altText) > maxAltTextLength {
+		return "", fmt.Errorf("alt_text must be at most %d characters", maxAltTextLength)
+	}
+
+	return altText, nil
+}
+
+// validateMediaIDs checks the attachments referenced by a new post.
+func validateMediaIDs(ids []uint64) error {
+	if len(ids) > MaxPostMedia {
+		return fmt.Errorf("a post can have at most %d media attachments", MaxPostMedia)
+	}
+
+	seen := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return errors.New("media_ids must not repeat an attachment")
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPostMentions bounds how many mentions of a post are linked, which also
+// bounds the notifications one post can send.
+const maxPostMentions = 20
+
+// Mention is an @username in a post's content that refers to a user. Start
+// and End are offsets in characters (Unicode code points) into the content,
+// End exclusive, and include the @.
+type Mention struct {
+	UserID   uint64 `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// ExtractMentions returns the @usernames in content, with Username as
+// written and UserID unset. An @ that follows a username character, as in
+// an email address, does not start a mention, and trailing dots are left out
+// so that "@alice." mentions alice.
+func ExtractMentions(content string) []Mention {
+	var mentions []Mention
+
+	var previous rune
+	offset := 0
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRuneInString(content[i:])
+		if r != '@' || isUsernameRune(previous) || previous == '@' {
+			previous = r
+			i += size
+			offset++
+			continue
+		}
+
+		end := i + size
+		for end < len(content) && isUsernameRune(rune(content[end])) {
+			end++
+		}
+
+		// Usernames are ASCII, so bytes and characters line up from here.
+		username := strings.TrimRight(content[i+size:end], ".")
+		if ValidateUsername(username) == nil {
+			mentions = append(mentions, Mention{
+				Username: username,
+				Start:    offset,
+				End:      offset + 1 + len(username),
+			})
+			if len(mentions) == maxPostMentions {
+				break
+			}
+		}
+
+		previous = r
+		if end > i+size {
+			previous = rune(content[end-1])
+		}
+		offset += 1 + end - (i + size)
+		i = end
+	}
+
+	return mentions
+}
+
+func isUsernameRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.'
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Sort keys of lists, which decide what a cursor's Value may hold.
+const (
+	SortByTime = "time"
+	SortByText = "text"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a list ordered by a sort key and then by ID. Value
+// is the sort key of the last item of the previous page, e.g. its created_at.
+type Cursor struct {
+	Value string `json:"v"`
+	ID    uint64 `json:"id"`
+}
+
+// TimeCursor returns the cursor of an item in a list ordered by time.
+func TimeCursor(t time.Time, id uint64) Cursor {
+	return Cursor{Value: t.Format(time.RFC3339Nano), ID: id}
+}
+
+// Encode returns the opaque form of the cursor handed to clients.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode for a list ordered by
+// sortKey. Cursors are opaque but not signed, so Value is checked to be a
+// valid sort key before it reaches a query.
+func DecodeCursor(encoded string, sortKey string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	switch sortKey {
+	case SortByTime:
+		t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil || t.Year() < 1 {
+			return nil, ErrInvalidCursor
+		}
+	case SortByText:
+		if !utf8.ValidString(cursor.Value) || strings.ContainsRune(cursor.Value, 0) {
+			return nil, ErrInvalidCursor
+		}
+	default:
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// PageRequest asks for the Limit items that follow After, or the first Limit
+// items when After is nil.
+type PageRequest struct {
+	Limit int
+	After *Cursor
+}
+
+// NewPageRequest builds a page request from the limit and cursor query
+// parameters of a list ordered by sortKey.
+func NewPageRequest(limit, cursor string, sortKey string) (PageRequest, error) {
+	page := PageRequest{Limit: DefaultPageLimit}
+
+	if limit != "" {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit < 1 {
+			return page, errors.New("limit must be a positive number")
+		}
+		page.Limit = min(parsedLimit, MaxPageLimit)
+	}
+
+	if cursor != "" {
+		after, err := DecodeCursor(cursor, sortKey)
+		if err != nil {
+			return page, err
+		}
+		page.After = after
+	}
+
+	return page, nil
+}
+
+// AfterValue returns the sort key and ID of the cursor as query arguments.
+// The sort key is nil on the first page, so queries can use
+// "$n::timestamp IS NULL OR (created_at, id) < ($n, $m)".
+func (p PageRequest) AfterValue() (interface{}, uint64) {
+	if p.After == nil {
+		return nil, 0
+	}
+	return p.After.Value, p.After.ID
+}
+
+// Page is one page of a list. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// NewPage builds a page from up to Limit+1 items fetched for request, so
+// that the extra item tells whether there is a next page. cursor returns the
+// cursor of the item at index i.
+func NewPage[T any](items []T, request PageRequest, cursor func(i int) Cursor) Page[T] {
+	page := Page[T]{Data: items}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+
+	if len(items) > request.Limit {
+		page.Data = items[:request.Limit]
+		page.HasMore = true
+		page.NextCursor = cursor(request.Limit - 1).Encode()
+	}
+
+	return page
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+// PasswordReset is a single-use reset token. Only the hash of the token is
+// stored.
+type PasswordReset struct {
+	ID        uint64     `json:"id,omitempty"`
+	UserID    uint64     `json:"user_id,omitempty"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at,omitempty"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at,omitempty"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+	Token           string `json:"token"`
+}
+
+// Prepare validates the request and hashes the new password. method is
+// "change" when the current password must be supplied and "reset" when a
+// reset token must be.
+func (change *PasswordChange) Prepare(method string) error {
+	if method == "change" && change.CurrentPassword == "" {
+		return errors.New("current_password is required")
+	}
+
+	if method == "reset" && change.Token == "" {
+		return errors.New("token is required")
+	}
+
+	if len(change.NewPassword) < minPasswordLength {
+		return errors.New("new_password must be at least 8 characters")
+	}
+
+	hashedPassword, err := HashPassword(change.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	change.NewPassword = hashedPassword
+	return nil
+}
+
+// HashPassword hashes a plain-text password with bcrypt.
+func HashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashedPassword), nil
+}
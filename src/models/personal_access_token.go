@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// PersonalAccessToken is a long-lived, scoped credential for scripts and
+// bots. Token is only set in the response that creates it.
+type PersonalAccessToken struct {
+	ID            uint64     `json:"id,omitempty"`
+	UserID        uint64     `json:"user_id,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Token         string     `json:"token,omitempty"`
+	TokenHash     string     `json:"-"`
+	Scopes        []string   `json:"scopes"`
+	ExpiresInDays int        `json:"expires_in_days,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at,omitempty"`
+}
+
+func (token *PersonalAccessToken) Prepare() error {
+	token.Name = strings.TrimSpace(token.Name)
+
+	if token.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(token.Name) > 100 {
+		return errors.New("name must be at most 100 characters")
+	}
+
+	if token.ExpiresInDays < 0 {
+		return errors.New("expires_in_days must be positive")
+	}
+
+	if token.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, token.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	return nil
+}
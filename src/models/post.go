@@ -7,6 +7,23 @@ import (
 	"time"
 )
 
+// Visibility levels of a post, from the widest to the narrowest. Authors
+// always see their own posts.
+const (
+	// VisibilityPublic posts are visible to every user.
+	VisibilityPublic = "public"
+	// VisibilityFollowers posts are visible to the author's followers.
+	VisibilityFollowers = "followers"
+	// VisibilityDirect posts are visible to the users they mention.
+	VisibilityDirect = "direct"
+)
+
+var visibilityRanks = map[string]int{
+	VisibilityPublic:    0,
+	VisibilityFollowers: 1,
+	VisibilityDirect:    2,
+}
+
 type Post struct {
 	ID               uint64    `json:"id,omitempty"`
 	ParentID         *uint64   `json:"parent_id,omitempty"`
@@ -19,6 +36,49 @@ type Post struct {
 	CurrentUserLiked bool      `json:"current_user_liked"`
 	TotalReplies     uint64    `json:"total_replies"`
 	Replies          []Post    `json:"replies,omitempty"`
+
+	// Visibility is one of the Visibility constants, public by default.
+	Visibility string `json:"visibility"`
+
+	// RootID is the top-level post of the conversation a reply belongs to.
+	RootID *uint64 `json:"root_id,omitempty"`
+
+	// EditedAt is when the content was last edited; RevisionCount is how
+	// many times it was.
+	EditedAt      *time.Time `json:"edited_at,omitempty"`
+	RevisionCount uint64     `json:"revision_count"`
+
+	// DeletedAt is set on tombstones, the deleted posts shown in threads so
+	// that their replies keep their place. DeletedBy is "author" or
+	// "moderator".
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy string     `json:"deleted_by,omitempty"`
+
+	TotalReposts        uint64 `json:"total_reposts"`
+	CurrentUserReposted bool   `json:"current_user_reposted"`
+	// QuoteID is the post this one quotes. Quote embeds it, without its own
+	// quote or replies.
+	QuoteID *uint64 `json:"quote_id,omitempty"`
+	Quote   *Post   `json:"quote,omitempty"`
+	// RepostedBy is set on home timeline entries that are there because
+	// someone the user follows reposted the post.
+	RepostedBy *Repost `json:"reposted_by,omitempty"`
+
+	// MediaIDs references attachments uploaded through POST /media when
+	// creating a post; Media is returned in their order.
+	MediaIDs []uint64          `json:"media_ids,omitempty"`
+	Media    []MediaAttachment `json:"media,omitempty"`
+
+	// Mentions are the @usernames in Content that link to a user.
+	Mentions []Mention `json:"mentions,omitempty"`
+}
+
+// Repost is a user sharing a post with their followers.
+type Repost struct {
+	UserID    uint64    `json:"user_id"`
+	Name      string    `json:"name"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 func (post *Post) Prepare() error {
@@ -31,10 +91,18 @@ func (post *Post) Prepare() error {
 }
 
 func (post *Post) validate() error {
-	if post.Content == "" {
+	if strings.TrimSpace(post.Content) == "" && len(post.MediaIDs) == 0 {
 		return errors.New("content is required")
 	}
 
+	if err := validateMediaIDs(post.MediaIDs); err != nil {
+		return err
+	}
+
+	if _, ok := visibilityRanks[post.Visibility]; post.Visibility != "" && !ok {
+		return errors.New("visibility must be public, followers or direct")
+	}
+
 	if post.AuthorID == 0 {
 		return errors.New("author is required")
 	}
@@ -42,6 +110,43 @@ func (post *Post) validate() error {
 	return nil
 }
 
+// LimitVisibility narrows the visibility of a reply to that of the post it
+// answers, so that replying never shows a conversation to more people.
+func (post *Post) LimitVisibility(parent string) {
+	if visibilityRanks[parent] > visibilityRanks[post.Visibility] {
+		post.Visibility = parent
+	}
+}
+
+// Tombstone strips a deleted post of everything but its place in its thread.
+func (post *Post) Tombstone() {
+	post.Content = ""
+	post.AuthorID = 0
+	post.AuthorName = ""
+	post.Username = ""
+	post.QuoteID = nil
+	post.Quote = nil
+	post.Media = nil
+	post.Mentions = nil
+}
+
 func (post *Post) format() {
 	post.Content = strings.TrimSpace(post.Content)
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+}
+
+// Thread is a post in its conversation: the posts it answers, root first,
+// and a page of the replies below it.
+type Thread struct {
+	Ancestors []Post     `json:"ancestors"`
+	Post      Post       `json:"post"`
+	Replies   Page[Post] `json:"replies"`
+}
+
+// PostRevision is one version of a post's content and when it was published.
+type PostRevision struct {
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -1,8 +1,20 @@
 package models
 
 type Profile struct {
-	User           User   `json:"user"`
-	Posts          []Post `json:"posts"`
-	FollowersCount int    `json:"followers_count"`
-	FollowingCount int    `json:"following_count"`
+	User           User       `json:"user"`
+	Posts          Page[Post] `json:"posts"`
+	FollowersCount uint64     `json:"followers_count"`
+	FollowingCount uint64     `json:"following_count"`
+	PostsCount     uint64     `json:"posts_count"`
+	Relationship
+}
+
+// Relationship describes how the viewer of a profile is connected to its
+// user. MutualFollowersCount is how many of the user's followers the viewer
+// follows. Blocking is whether the viewer blocked the user.
+type Relationship struct {
+	Following            bool   `json:"following"`
+	FollowedBy           bool   `json:"followed_by"`
+	MutualFollowersCount uint64 `json:"mutual_followers_count"`
+	Blocking             bool   `json:"blocking"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Session is a single refresh token. Tokens obtained from the same login share
+// a FamilyID, which is also the session ID carried by access tokens.
+type Session struct {
+	ID        uint64     `json:"id,omitempty"`
+	FamilyID  string     `json:"family_id,omitempty"`
+	UserID    uint64     `json:"user_id,omitempty"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at,omitempty"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
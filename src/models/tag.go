@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the longest hashtag, without the #, that is recognized.
+const MaxTagLength = 50
+
+// maxPostTags bounds how many hashtags of a post are indexed.
+const maxPostTags = 30
+
+var ErrInvalidTag = errors.New("tag must be 1 to 50 letters, digits or underscores and contain a letter")
+
+// Trend is a hashtag that is used more than usual. Score decays with the
+// age of each use, and each account counts once per tag.
+type Trend struct {
+	Tag      string  `json:"tag"`
+	Score    float64 `json:"score"`
+	Uses     uint64  `json:"uses"`
+	Accounts uint64  `json:"accounts"`
+}
+
+// ExtractHashtags returns the distinct hashtags in content, normalized to
+// lower case, in the order they first appear. A hashtag is a # that does not
+// follow a letter, digit or underscore, followed by letters, digits and
+// underscores including at least one letter, so "#1" or "a#b" are not tags.
+func ExtractHashtags(content string) []string {
+	var tags []string
+	seen := map[string]bool{}
+
+	var previous rune
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRuneInString(content[i:])
+		if r != '#' || isTagRune(previous) {
+			previous = r
+			i += size
+			continue
+		}
+
+		previous = r
+		end := i + size
+		for end < len(content) {
+			next, nextSize := utf8.DecodeRuneInString(content[end:])
+			if !isTagRune(next) {
+				break
+			}
+			previous = next
+			end += nextSize
+		}
+
+		tag, err := NormalizeTag(content[i+size : end])
+		if err == nil && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+			if len(tags) == maxPostTags {
+				break
+			}
+		}
+
+		i = end
+	}
+
+	return tags
+}
+
+// NormalizeTag validates a hashtag given without its # and returns it in
+// lower case, the form tags are stored and looked up in.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return "", ErrInvalidTag
+		}
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		}
+	}
+
+	if !hasLetter {
+		return "", ErrInvalidTag
+	}
+
+	return tag, nil
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// TwoFactor is a user's TOTP enrollment. A secret without EnabledAt is an
+// enrollment that has not been confirmed yet.
+type TwoFactor struct {
+	UserID      uint64     `json:"user_id,omitempty"`
+	Secret      string     `json:"-"`
+	EnabledAt   *time.Time `json:"enabled_at,omitempty"`
+	LastCounter int64      `json:"-"`
+}
+
+func (twoFactor *TwoFactor) Enabled() bool {
+	return twoFactor.EnabledAt != nil
+}
+
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"`
+}
+
+type TwoFactorCode struct {
+	ChallengeToken string `json:"challenge_token,omitempty"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+	Password       string `json:"password,omitempty"`
+}
@@ -2,23 +2,64 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/badoux/checkmail"
-	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	minUsernameLength  = 3
+	MaxUsernameLength  = 30
+	maxNameLength      = 100
+	maxBioLength       = 160
+	maxAvatarURLLength = 200
+)
+
+// reservedUsernames cannot be registered because they would be mistaken for
+// the service itself or collide with its URLs.
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "api": true, "auth": true, "help": true,
+	"login": true, "logout": true, "me": true, "moderator": true, "root": true,
+	"settings": true, "support": true, "system": true, "users": true,
+}
+
 type User struct {
 	ID        uint64    `json:"id,omitempty"`
 	Name      string    `json:"name,omitempty"`
 	Email     string    `json:"email,omitempty"`
 	Username  string    `json:"username,omitempty"`
 	AvatarURL string    `json:"avatar_url,omitempty"`
+	BannerURL string    `json:"banner_url,omitempty"`
 	Bio       string    `json:"bio,omitempty"`
 	Birthdate string    `json:"birthdate,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	Password  string    `json:"password,omitempty"`
+	Role      string    `json:"role,omitempty"`
+
+	FollowerCount  uint64 `json:"follower_count,omitempty"`
+	FollowingCount uint64 `json:"following_count,omitempty"`
+	PostCount      uint64 `json:"post_count,omitempty"`
+
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+
+	EmailVerifiedAt    *time.Time `json:"email_verified_at,omitempty"`
+	VerificationSentAt *time.Time `json:"-"`
+}
+
+// Public returns a copy of the user without the fields only the user may see.
+func (user User) Public() User {
+	user.Email = ""
+	user.Birthdate = ""
+	user.Password = ""
+	user.Role = ""
+	user.EmailVerifiedAt = nil
+	user.VerificationSentAt = nil
+	user.SuspendedAt = nil
+	return user
 }
 
 func (user *User) Prepare(method string) error {
@@ -44,8 +85,12 @@ func (user *User) validate(method string) error {
 		return errors.New("email is required")
 	}
 
-	if user.Username == "" {
-		return errors.New("username is required")
+	if err := ValidateUsername(user.Username); err != nil {
+		return err
+	}
+
+	if utf8.RuneCountInString(user.Bio) > maxBioLength {
+		return fmt.Errorf("bio must be at most %d characters", maxBioLength)
 	}
 
 	if err := checkmail.ValidateFormat(user.Email); err != nil {
@@ -77,16 +122,115 @@ func (user *User) format(method string) error {
 	user.Birthdate = strings.TrimSpace(user.Birthdate)
 
 	if method == "create" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		hashedPassword, err := HashPassword(user.Password)
 		if err != nil {
 			return err
 		}
 
-		user.Password = string(hashedPassword)
+		user.Password = hashedPassword
 	}
 
-	parsedBirthdate, _ := time.Parse("01-02-2006", user.Birthdate)
+	parsedBirthdate, _ := time.Parse("02-01-2006", user.Birthdate)
 	user.Birthdate = parsedBirthdate.Format("2006-01-02")
 
 	return nil
 }
+
+// ValidateUsername checks that a username is 3 to 30 letters, digits,
+// underscores or dots and is not reserved.
+func ValidateUsername(username string) error {
+	if username == "" {
+		return errors.New("username is required")
+	}
+
+	if len(username) < minUsernameLength || len(username) > MaxUsernameLength {
+		return fmt.Errorf("username must be between %d and %d characters", minUsernameLength, MaxUsernameLength)
+	}
+
+	for _, c := range username {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '_' && c != '.' {
+			return errors.New("username may only contain letters, digits, underscores and dots")
+		}
+	}
+
+	if reservedUsernames[strings.ToLower(username)] {
+		return errors.New("username is reserved")
+	}
+
+	return nil
+}
+
+// UserUpdate is a partial update of a user. Only the fields present in the
+// request are changed; an empty avatar_url, bio or birthdate clears it.
+type UserUpdate struct {
+	Name      *string `json:"name"`
+	Username  *string `json:"username"`
+	AvatarURL *string `json:"avatar_url"`
+	Bio       *string `json:"bio"`
+	Birthdate *string `json:"birthdate"`
+	Email     *string `json:"email"`
+}
+
+// Prepare trims and validates the fields present in the update and converts
+// the birthdate to the database format.
+func (update *UserUpdate) Prepare() error {
+	for _, field := range []*string{update.Name, update.Username, update.AvatarURL, update.Bio, update.Birthdate, update.Email} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+
+	if update.Name == nil && update.Username == nil && update.AvatarURL == nil &&
+		update.Bio == nil && update.Birthdate == nil && update.Email == nil {
+		return errors.New("no fields to update")
+	}
+
+	if update.Name != nil {
+		if *update.Name == "" {
+			return errors.New("name is required")
+		}
+		if utf8.RuneCountInString(*update.Name) > maxNameLength {
+			return fmt.Errorf("name must be at most %d characters", maxNameLength)
+		}
+	}
+
+	if update.Username != nil {
+		if err := ValidateUsername(*update.Username); err != nil {
+			return err
+		}
+	}
+
+	if update.AvatarURL != nil && *update.AvatarURL != "" {
+		if len(*update.AvatarURL) > maxAvatarURLLength {
+			return fmt.Errorf("avatar_url must be at most %d characters", maxAvatarURLLength)
+		}
+
+		parsed, err := url.Parse(*update.AvatarURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("avatar_url must be an http or https URL")
+		}
+	}
+
+	if update.Bio != nil && utf8.RuneCountInString(*update.Bio) > maxBioLength {
+		return fmt.Errorf("bio must be at most %d characters", maxBioLength)
+	}
+
+	if update.Birthdate != nil && *update.Birthdate != "" {
+		parsedBirthdate, err := time.Parse("02-01-2006", *update.Birthdate)
+		if err != nil {
+			return errors.New("invalid birthdate format, use DD-MM-YYYY")
+		}
+		*update.Birthdate = parsedBirthdate.Format("2006-01-02")
+	}
+
+	if update.Email != nil {
+		if *update.Email == "" {
+			return errors.New("email is required")
+		}
+		if err := checkmail.ValidateFormat(*update.Email); err != nil {
+			return errors.New("invalid email format")
+		}
+	}
+
+	return nil
+}
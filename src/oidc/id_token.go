@@ -0,0 +1,83 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// IDToken holds the claims of an ID token we rely on.
+type IDToken struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Picture           string   `json:"picture"`
+}
+
+// Valid implements jwt.Claims.
+func (t *IDToken) Valid() error {
+	now := time.Now().Unix()
+
+	if t.ExpiresAt == 0 || now > t.ExpiresAt {
+		return errors.New("id token is expired")
+	}
+
+	if t.IssuedAt > now+60 {
+		return errors.New("id token used before issued")
+	}
+
+	return nil
+}
+
+// audience is the aud claim, which may be a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexBool accepts both true and "true", since some providers send
+// email_verified as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = flexBool(v == "true")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+)
+
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+}
+
+type keySet map[string]interface{}
+
+// key returns the provider key with the given ID, refetching the provider's
+// JWKS once when the ID is unknown so that provider key rotation is picked
+// up.
+func (p *Provider) key(ctx context.Context, d *discovery, kid string, alg string) (interface{}, error) {
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+
+	if key, ok := keys.lookup(kid); ok {
+		return checkAlgorithm(key, alg)
+	}
+
+	var body struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, d.JWKSURI, &body); err != nil {
+		return nil, err
+	}
+
+	fetched := keySet{}
+	for _, k := range body.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			fetched[k.KeyID] = key
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = &fetched
+	p.mu.Unlock()
+
+	key, ok := fetched.lookup(kid)
+	if !ok {
+		return nil, errors.New("unknown id token signing key")
+	}
+	return checkAlgorithm(key, alg)
+}
+
+func (s *keySet) lookup(kid string) (interface{}, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	if key, ok := (*s)[kid]; ok {
+		return key, true
+	}
+
+	// Providers with a single key sometimes omit the kid header.
+	if kid == "" && len(*s) == 1 {
+		for _, key := range *s {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+// checkAlgorithm makes sure the token's alg header matches the key type, so a
+// public key can never be used as an HMAC secret.
+func checkAlgorithm(key interface{}, alg string) (interface{}, error) {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if alg == "RS256" || alg == "RS384" || alg == "RS512" || alg == "PS256" || alg == "PS384" || alg == "PS512" {
+			return key, nil
+		}
+	case *ecdsa.PublicKey:
+		if alg == "ES256" || alg == "ES384" || alg == "ES512" {
+			return key, nil
+		}
+	case ed25519.PublicKey:
+		if alg == "EdDSA" {
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("unexpected id token signing algorithm")
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.New("unsupported key type")
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	_ "project01/src/auth" // registers the EdDSA signing method
+	"project01/src/config"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Provider runs the authorization code flow with PKCE against an OpenID
+// Connect provider. Its discovery document and keys are fetched lazily.
+type Provider struct {
+	Config config.OIDCProvider
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+var (
+	providersMu sync.Mutex
+	providers   = map[string]*Provider{}
+)
+
+// Get returns the configured provider called name.
+func Get(name string) (*Provider, error) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if provider, ok := providers[name]; ok {
+		return provider, nil
+	}
+
+	providerConfig, ok := config.OIDCProviders[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	provider := &Provider{Config: providerConfig}
+	providers[name] = provider
+	return provider, nil
+}
+
+// NewPKCE returns a code verifier and its S256 code challenge (RFC 7636).
+func NewPKCE() (string, string, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// NewNonce returns a random value for the state and nonce parameters.
+func NewNonce() (string, error) {
+	return randomString(24)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL returns the URL to send the user to.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.Config.ClientID)
+	params.Set("redirect_uri", p.Config.RedirectURL)
+	params.Set("scope", strings.Join(p.Config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified claims of
+// the ID token. nonce must be the value sent with the authorization request.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDToken, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("client_id", p.Config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.Config.ClientSecret != "" {
+		form.Set("client_secret", p.Config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return nil, fmt.Errorf("token exchange failed: %s %s", body.Error, body.ErrorDescription)
+	}
+
+	return p.verify(ctx, d, body.IDToken, nonce)
+}
+
+func (p *Provider) verify(ctx context.Context, d *discovery, rawIDToken, nonce string) (*IDToken, error) {
+	var claims IDToken
+
+	_, err := jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, d, kid, token.Method.Alg())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != d.Issuer {
+		return nil, errors.New("id token has the wrong issuer")
+	}
+
+	if !claims.Audience.contains(p.Config.ClientID) {
+		return nil, errors.New("id token has the wrong audience")
+	}
+
+	if claims.Nonce != nonce {
+		return nil, errors.New("id token has the wrong nonce")
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+
+	return &claims, nil
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	wellKnown := strings.TrimSuffix(p.Config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, wellKnown, &d); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(p.Config.Issuer, "/") {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", d.Issuer, p.Config.Issuer)
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"project01/src/config"
+	"project01/src/oidc/oidctest"
+	"strings"
+	"testing"
+)
+
+// signIn runs an authorization request against server and returns the
+// authorization code, with the verifier and nonce that go with it.
+func signIn(t *testing.T, provider *Provider, server *oidctest.Server) (string, string, string) {
+	t.Helper()
+
+	state, _ := NewNonce()
+	nonce, _ := NewNonce()
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorizationURL, err := provider.AuthCodeURL(context.Background(), state, nonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback, err := server.Authorize(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if callback.Query().Get("state") != state {
+		t.Fatalf("callback state = %q, want %q", callback.Query().Get("state"), state)
+	}
+
+	return callback.Query().Get("code"), verifier, nonce
+}
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Server) {
+	t.Helper()
+
+	server := oidctest.NewServer("client")
+	t.Cleanup(server.Close)
+	server.User = oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true}
+
+	provider := &Provider{Config: config.OIDCProvider{
+		Name:        "mock",
+		Issuer:      server.Issuer(),
+		ClientID:    "client",
+		Scopes:      []string{"openid", "email"},
+		RedirectURL: "http://localhost:8080/auth/mock/callback",
+	}}
+
+	return provider, server
+}
+
+func TestAuthCodeURLUsesDiscovery(t *testing.T) {
+	provider, server := newTestProvider(t)
+
+	authorizationURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(authorizationURL, server.Issuer()+"/authorize?") {
+		t.Fatalf("authorization URL %q does not use the discovered endpoint", authorizationURL)
+	}
+
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge") != "challenge" || query.Get("code_challenge_method") != "S256" ||
+		query.Get("nonce") != "nonce" || query.Get("state") != "state" {
+		t.Fatalf("authorization URL has the wrong parameters: %v", query)
+	}
+}
+
+func TestDiscoveryRejectsOtherIssuer(t *testing.T) {
+	provider, server := newTestProvider(t)
+	provider.Config.Issuer = server.Issuer() + "/"
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); err != nil {
+		t.Fatalf("a trailing slash should not matter: %v", err)
+	}
+
+	other, _ := newTestProvider(t)
+	other.Config.Issuer = server.Issuer() + "/tenant"
+
+	_, err := other.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected an issuer mismatch, got %v", err)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	provider, server := newTestProvider(t)
+
+	code, verifier, nonce := signIn(t, provider, server)
+	idToken, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idToken.Subject != "alice" || idToken.Email != "alice@example.com" || !bool(idToken.EmailVerified) {
+		t.Fatalf("unexpected claims: %+v", idToken)
+	}
+
+	if _, err = provider.Exchange(context.Background(), code, verifier, nonce); err == nil {
+		t.Fatal("expected an authorization code to be redeemable only once")
+	}
+}
+
+func TestExchangeChecksPKCE(t *testing.T) {
+	provider, server := newTestProvider(t)
+
+	code, _, nonce := signIn(t, provider, server)
+	otherVerifier, _, _ := NewPKCE()
+
+	if _, err := provider.Exchange(context.Background(), code, otherVerifier, nonce); err == nil {
+		t.Fatal("expected the exchange to fail with the wrong code verifier")
+	}
+}
+
+func TestExchangeChecksNonce(t *testing.T) {
+	provider, server := newTestProvider(t)
+
+	code, verifier, nonce := signIn(t, provider, server)
+	server.Nonce = "replayed"
+
+	_, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("expected a nonce error, got %v", err)
+	}
+}
+
+func TestExchangeFollowsKeyRotation(t *testing.T) {
+	provider, server := newTestProvider(t)
+
+	code, verifier, nonce := signIn(t, provider, server)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	code, verifier, nonce = signIn(t, provider, server)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := server.JWKSRequests(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1 while the key is unchanged", got)
+	}
+
+	server.RotateKey()
+
+	code, verifier, nonce = signIn(t, provider, server)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("token signed with the rotated key was rejected: %v", err)
+	}
+
+	if got := server.JWKSRequests(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2 after a rotation", got)
+	}
+}
@@ -0,0 +1,90 @@
+package policy
+
+import "project01/src/auth"
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// ValidRole reports whether role is one of the roles a user can have.
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
+// Action is something a principal can be allowed to do.
+type Action string
+
+const (
+	UpdateUser    Action = "users:update"
+	DeleteUser    Action = "users:delete"
+	SuspendUser   Action = "users:suspend"
+	UnsuspendUser Action = "users:unsuspend"
+	ManageRoles   Action = "users:manage_roles"
+	UpdatePost    Action = "posts:update"
+	DeletePost    Action = "posts:delete"
+	ViewAuditLogs Action = "audit_logs:read"
+)
+
+// Resource is the object an action is performed on. OwnerID is the user the
+// object belongs to: the author of a post, or the user itself.
+type Resource struct {
+	OwnerID uint64
+}
+
+type rule struct {
+	// owner allows the owner of the resource.
+	owner bool
+	// roles are allowed on any resource.
+	roles []string
+}
+
+var rules = map[Action]rule{
+	UpdateUser:    {owner: true},
+	DeleteUser:    {owner: true, roles: []string{RoleAdmin}},
+	SuspendUser:   {roles: []string{RoleAdmin}},
+	UnsuspendUser: {roles: []string{RoleAdmin}},
+	ManageRoles:   {roles: []string{RoleAdmin}},
+	UpdatePost:    {owner: true},
+	DeletePost:    {owner: true, roles: []string{RoleModerator, RoleAdmin}},
+	ViewAuditLogs: {roles: []string{RoleAdmin}},
+}
+
+// Can reports whether principal may perform action on resource. A nil
+// resource checks the action itself, which only roles can grant.
+func Can(principal *auth.Principal, action Action, resource *Resource) bool {
+	if principal == nil {
+		return false
+	}
+
+	rule, ok := rules[action]
+	if !ok {
+		return false
+	}
+
+	if rule.owner && resource != nil && resource.OwnerID == principal.UserID {
+		return true
+	}
+
+	return hasRole(principal, rule.roles)
+}
+
+// Privileged reports whether principal may perform action on resource only
+// because of its role. Privileged actions are recorded in the audit log.
+func Privileged(principal *auth.Principal, action Action, resource *Resource) bool {
+	if !Can(principal, action, resource) {
+		return false
+	}
+
+	return !rules[action].owner || resource == nil || resource.OwnerID != principal.UserID
+}
+
+func hasRole(principal *auth.Principal, roles []string) bool {
+	for _, role := range roles {
+		if principal.Role == role {
+			return true
+		}
+	}
+	return false
+}
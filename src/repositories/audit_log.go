@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"project01/src/models"
+)
+
+type AuditLogRepositoryInterface interface {
+	Create(entry models.AuditLog) error
+	FindAll() ([]models.AuditLog, error)
+}
+
+func NewAuditLogRepository(db *sql.DB) AuditLogRepositoryInterface {
+	return &AuditLogRepository{DB: db}
+}
+
+type AuditLogRepository struct {
+	DB *sql.DB
+}
+
+func (r *AuditLogRepository) Create(entry models.AuditLog) error {
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_logs (actor_id, action, target_type, target_id, metadata, ip_address)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = r.DB.Exec(query, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, metadata, entry.IPAddress)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FindAll returns the most recent audit log entries.
+func (r *AuditLogRepository) FindAll() ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+
+	query := `SELECT id, COALESCE(actor_id, 0), action, target_type, target_id, metadata, ip_address, created_at
+		FROM audit_logs
+		ORDER BY created_at DESC
+		LIMIT 100`
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditLog
+		var metadata []byte
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&metadata,
+			&entry.IPAddress,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(metadata, &entry.Metadata); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
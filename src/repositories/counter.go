@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"database/sql"
+)
+
+// CounterRepositoryInterface recomputes the denormalized counters on posts
+// and users from the rows they count.
+type CounterRepositoryInterface interface {
+	Repair() (uint64, uint64, error)
+}
+
+func NewCounterRepository(db *sql.DB) CounterRepositoryInterface {
+	return &CounterRepository{DB: db}
+}
+
+type CounterRepository struct {
+	DB *sql.DB
+}
+
+// Repair fixes every counter that has drifted and returns how many posts and
+// users it changed.
+func (r *CounterRepository) Repair() (uint64, uint64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE posts SET like_count = counts.like_count, reply_count = counts.reply_count,
+			repost_count = counts.repost_count
+		FROM (
+			SELECT posts.id,
+				(SELECT COUNT(*) FROM likes WHERE likes.post_id = posts.id) AS like_count,
+				(SELECT COUNT(*) FROM posts AS replies
+					WHERE replies.parent_id = posts.id AND replies.deleted_at IS NULL) AS reply_count,
+				(SELECT COUNT(*) FROM reposts WHERE reposts.post_id = posts.id) AS repost_count
+			FROM posts
+		) AS counts
+		WHERE posts.id = counts.id
+			AND (posts.like_count <> counts.like_count OR posts.reply_count <> counts.reply_count
+				OR posts.repost_count <> counts.repost_count)`
+	result, err := tx.Exec(query)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	postsFixed, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query = `UPDATE users SET follower_count = counts.follower_count, following_count = counts.following_count,
+			post_count = counts.post_count
+		FROM (
+			SELECT users.id,
+				(SELECT COUNT(*) FROM followers WHERE followers.user_id = users.id) AS follower_count,
+				(SELECT COUNT(*) FROM followers WHERE followers.follower_id = users.id) AS following_count,
+				(SELECT COUNT(*) FROM posts
+					WHERE posts.author_id = users.id AND posts.parent_id IS NULL AND posts.deleted_at IS NULL) AS post_count
+			FROM users
+		) AS counts
+		WHERE users.id = counts.id
+			AND (users.follower_count <> counts.follower_count OR users.following_count <> counts.following_count
+				OR users.post_count <> counts.post_count)`
+	result, err = tx.Exec(query)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	usersFixed, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return uint64(postsFixed), uint64(usersFixed), nil
+}
@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"project01/src/models"
+	"time"
+)
+
+var ErrIdentityTaken = errors.New("identity is already linked to another account")
+
+type IdentityRepositoryInterface interface {
+	FindByProviderSubject(provider, subject string) (*models.Identity, error)
+	FindAll(userID uint64) ([]models.Identity, error)
+	Create(identity *models.Identity) error
+	CreateWithUser(user *models.User, identity *models.Identity) error
+	Delete(userID, id uint64) error
+	Count(userID uint64) (int, error)
+	CreateState(state *models.OAuthState) error
+	ConsumeState(stateHash string) (*models.OAuthState, error)
+}
+
+func NewIdentityRepository(db *sql.DB) IdentityRepositoryInterface {
+	return &IdentityRepository{DB: db}
+}
+
+type IdentityRepository struct {
+	DB *sql.DB
+}
+
+func (r *IdentityRepository) FindByProviderSubject(provider, subject string) (*models.Identity, error) {
+	query := `SELECT id, user_id, provider, subject, email, created_at FROM identities WHERE provider = $1 AND subject = $2`
+
+	var identity models.Identity
+	err := r.DB.QueryRow(query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *IdentityRepository) FindAll(userID uint64) ([]models.Identity, error) {
+	var identities []models.Identity
+
+	query := `SELECT id, user_id, provider, subject, email, created_at FROM identities WHERE user_id = $1 ORDER BY created_at`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identity models.Identity
+		if err := rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// Create links an identity to an existing user.
+func (r *IdentityRepository) Create(identity *models.Identity) error {
+	query := `INSERT INTO identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, subject) DO NOTHING RETURNING id, created_at`
+
+	err := r.DB.QueryRow(query, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrIdentityTaken
+	}
+
+	return err
+}
+
+// CreateWithUser creates an account for a first-time external sign-in. The
+// account has no password and its email is verified by the provider. When
+// the requested username is taken a numeric suffix is appended.
+func (r *IdentityRepository) CreateWithUser(user *models.User, identity *models.Identity) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Leave room for the suffix, so that it never makes the username too long.
+	base := user.Username
+	if len(base) > models.MaxUsernameLength-4 {
+		base = base[:models.MaxUsernameLength-4]
+	}
+
+	username := user.Username
+	for attempt := 0; ; attempt++ {
+		var taken bool
+		if err = tx.QueryRow(usernameTakenQuery, username, 0).Scan(&taken); err != nil {
+			return err
+		}
+
+		if !taken {
+			break
+		}
+
+		if attempt == 10 {
+			return errors.New("could not find a free username")
+		}
+
+		username = fmt.Sprintf("%s%04d", base, rand.Intn(10000))
+	}
+	user.Username = username
+
+	query := `INSERT INTO users (name, email, username, avatar_url, bio, password, email_verified_at)
+		VALUES ($1, $2, $3, $4, '', '', CURRENT_TIMESTAMP) RETURNING id, created_at`
+	err = tx.QueryRow(query, user.Name, user.Email, user.Username, user.AvatarURL).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	identity.UserID = user.ID
+	query = `INSERT INTO identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err = tx.QueryRow(query, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *IdentityRepository) Delete(userID, id uint64) error {
+	result, err := r.DB.Exec(`DELETE FROM identities WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *IdentityRepository) Count(userID uint64) (int, error) {
+	var count int
+	err := r.DB.QueryRow(`SELECT COUNT(*) FROM identities WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *IdentityRepository) CreateState(state *models.OAuthState) error {
+	query := `INSERT INTO oauth_states (state_hash, provider, code_verifier, nonce, link_user_id, binding_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.DB.Exec(query, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.LinkUserID, state.BindingHash, state.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeState deletes and returns a pending authorization request, so that
+// each state can only complete one callback.
+func (r *IdentityRepository) ConsumeState(stateHash string) (*models.OAuthState, error) {
+	query := `DELETE FROM oauth_states WHERE state_hash = $1
+		RETURNING state_hash, provider, code_verifier, nonce, link_user_id, binding_hash, expires_at`
+
+	var state models.OAuthState
+	err := r.DB.QueryRow(query, stateHash).Scan(
+		&state.StateHash,
+		&state.Provider,
+		&state.CodeVerifier,
+		&state.Nonce,
+		&state.LinkUserID,
+		&state.BindingHash,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if time.Now().After(state.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	return &state, nil
+}
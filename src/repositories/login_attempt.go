@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"database/sql"
+	"project01/src/models"
+)
+
+type LoginAttemptRepositoryInterface interface {
+	Create(attempt models.LoginAttempt) error
+	FindByUserID(userID uint64) ([]models.LoginAttempt, error)
+}
+
+func NewLoginAttemptRepository(db *sql.DB) LoginAttemptRepositoryInterface {
+	return &LoginAttemptRepository{DB: db}
+}
+
+type LoginAttemptRepository struct {
+	DB *sql.DB
+}
+
+func (r *LoginAttemptRepository) Create(attempt models.LoginAttempt) error {
+	query := `INSERT INTO login_attempts (user_id, email, ip_address, user_agent, success, reason)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))`
+	_, err := r.DB.Exec(query, attempt.UserID, attempt.Email, attempt.IPAddress, attempt.UserAgent, attempt.Success, attempt.Reason)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FindByUserID returns the most recent login attempts against an account.
+func (r *LoginAttemptRepository) FindByUserID(userID uint64) ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+
+	query := `SELECT id, user_id, email, ip_address, COALESCE(user_agent, ''), success, COALESCE(reason, ''), created_at
+		FROM login_attempts
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 100`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attempt models.LoginAttempt
+		if err := rows.Scan(
+			&attempt.ID,
+			&attempt.UserID,
+			&attempt.Email,
+			&attempt.IPAddress,
+			&attempt.UserAgent,
+			&attempt.Success,
+			&attempt.Reason,
+			&attempt.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		attempts = append(attempts, attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
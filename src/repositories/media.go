@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"project01/src/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrMediaUnavailable = errors.New("media not found or already attached to a post")
+
+type MediaRepositoryInterface interface {
+	Create(attachment *models.MediaAttachment) error
+	FindByID(id uint64) (*models.MediaAttachment, error)
+	UpdateAltText(id, userID uint64, altText string) error
+	DeleteUnattached(before time.Time) ([]string, error)
+	FindKeys(keys []string) ([]string, error)
+}
+
+func NewMediaRepository(db *sql.DB) MediaRepositoryInterface {
+	return &MediaRepository{DB: db}
+}
+
+type MediaRepository struct {
+	DB *sql.DB
+}
+
+const mediaColumns = `id, user_id, post_id, kind, content_type, url, key, thumbnail_url, thumbnail_key,
+	width, height, duration_ms, size, blurhash, alt_text, created_at`
+
+func scanMedia(row interface{ Scan(...interface{}) error }, attachment *models.MediaAttachment) error {
+	return row.Scan(
+		&attachment.ID,
+		&attachment.UserID,
+		&attachment.PostID,
+		&attachment.Kind,
+		&attachment.ContentType,
+		&attachment.URL,
+		&attachment.Key,
+		&attachment.ThumbnailURL,
+		&attachment.ThumbnailKey,
+		&attachment.Width,
+		&attachment.Height,
+		&attachment.DurationMS,
+		&attachment.Size,
+		&attachment.Blurhash,
+		&attachment.AltText,
+		&attachment.CreatedAt,
+	)
+}
+
+func (r *MediaRepository) Create(attachment *models.MediaAttachment) error {
+	query := `INSERT INTO media_attachments (user_id, kind, content_type, url, key, thumbnail_url, thumbnail_key,
+		width, height, duration_ms, size, blurhash, alt_text)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, created_at`
+
+	return r.DB.QueryRow(query,
+		attachment.UserID,
+		attachment.Kind,
+		attachment.ContentType,
+		attachment.URL,
+		attachment.Key,
+		attachment.ThumbnailURL,
+		attachment.ThumbnailKey,
+		attachment.Width,
+		attachment.Height,
+		attachment.DurationMS,
+		attachment.Size,
+		attachment.Blurhash,
+		attachment.AltText,
+	).Scan(&attachment.ID, &attachment.CreatedAt)
+}
+
+func (r *MediaRepository) FindByID(id uint64) (*models.MediaAttachment, error) {
+	var attachment models.MediaAttachment
+
+	row := r.DB.QueryRow(`SELECT `+mediaColumns+` FROM media_attachments WHERE id = $1`, id)
+	if err := scanMedia(row, &attachment); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// UpdateAltText changes the alt text of an attachment uploaded by userID.
+func (r *MediaRepository) UpdateAltText(id, userID uint64, altText string) error {
+	result, err := r.DB.Exec(`UPDATE media_attachments SET alt_text = $1 WHERE id = $2 AND user_id = $3`, altText, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteUnattached deletes the uploads created before the given time that no
+// post references, and returns the storage keys of their files.
+func (r *MediaRepository) DeleteUnattached(before time.Time) ([]string, error) {
+	query := `DELETE FROM media_attachments WHERE post_id IS NULL AND created_at < $1 RETURNING key, thumbnail_key`
+	rows, err := r.DB.Query(query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key, thumbnailKey string
+		if err := rows.Scan(&key, &thumbnailKey); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+		if thumbnailKey != "" {
+			keys = append(keys, thumbnailKey)
+		}
+	}
+
+	return keys, rows.Err()
+}
+
+// FindKeys returns which of keys are still the file or thumbnail of an
+// attachment.
+func (r *MediaRepository) FindKeys(keys []string) ([]string, error) {
+	query := `SELECT key FROM media_attachments WHERE key = ANY($1)
+		UNION SELECT thumbnail_key FROM media_attachments WHERE thumbnail_key = ANY($1)`
+	rows, err := r.DB.Query(query, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var used []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		used = append(used, key)
+	}
+
+	return used, rows.Err()
+}
+
+// attachMedia loads the attachments of posts, and of their replies, in one
+// query.
+func attachMedia(db *sql.DB, posts []models.Post) error {
+	var ids []int64
+	for _, post := range posts {
+		ids = append(ids, int64(post.ID))
+		for _, reply := range post.Replies {
+			ids = append(ids, int64(reply.ID))
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `SELECT ` + mediaColumns + ` FROM media_attachments WHERE post_id = ANY($1) ORDER BY post_id, position`
+	rows, err := db.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byPost := map[uint64][]models.MediaAttachment{}
+	for rows.Next() {
+		var attachment models.MediaAttachment
+		if err := scanMedia(rows, &attachment); err != nil {
+			return err
+		}
+		byPost[*attachment.PostID] = append(byPost[*attachment.PostID], attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Media = byPost[posts[i].ID]
+		for j := range posts[i].Replies {
+			posts[i].Replies[j].Media = byPost[posts[i].Replies[j].ID]
+		}
+	}
+
+	return nil
+}
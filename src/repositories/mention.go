@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"database/sql"
+	"project01/src/models"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// syncMentions replaces the mentions of a post with the @usernames in its
+// content that belong to a user. Others are left as plain text.
+func syncMentions(tx *sql.Tx, postID uint64, content string) error {
+	if _, err := tx.Exec(`DELETE FROM post_mentions WHERE post_id = $1`, postID); err != nil {
+		return err
+	}
+
+	mentions := models.ExtractMentions(content)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	usernames := make([]string, len(mentions))
+	for i, mention := range mentions {
+		usernames[i] = strings.ToLower(mention.Username)
+	}
+
+	rows, err := tx.Query(`SELECT id, LOWER(username) FROM users WHERE LOWER(username) = ANY($1)`, pq.Array(usernames))
+	if err != nil {
+		return err
+	}
+
+	userIDs := map[string]uint64{}
+	for rows.Next() {
+		var id uint64
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs[username] = id
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for i, mention := range mentions {
+		userID, ok := userIDs[usernames[i]]
+		if !ok {
+			continue
+		}
+
+		query := `INSERT INTO post_mentions (post_id, user_id, start_offset, end_offset) VALUES ($1, $2, $3, $4)`
+		if _, err = tx.Exec(query, postID, userID, mention.Start, mention.End); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachMentions loads the mentions of posts, and of their replies, in one
+// query. Username is the user's current one, which may differ in case or
+// altogether from what the content says.
+func attachMentions(db *sql.DB, posts []models.Post) error {
+	var ids []int64
+	for _, post := range posts {
+		ids = append(ids, int64(post.ID))
+		for _, reply := range post.Replies {
+			ids = append(ids, int64(reply.ID))
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `SELECT post_mentions.post_id, post_mentions.user_id, users.username,
+			post_mentions.start_offset, post_mentions.end_offset
+		FROM post_mentions
+		JOIN users ON users.id = post_mentions.user_id
+		WHERE post_mentions.post_id = ANY($1)
+		ORDER BY post_mentions.post_id, post_mentions.start_offset`
+	rows, err := db.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byPost := map[uint64][]models.Mention{}
+	for rows.Next() {
+		var postID uint64
+		var mention models.Mention
+		if err := rows.Scan(&postID, &mention.UserID, &mention.Username, &mention.Start, &mention.End); err != nil {
+			return err
+		}
+		byPost[postID] = append(byPost[postID], mention)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Mentions = byPost[posts[i].ID]
+		for j := range posts[i].Replies {
+			posts[i].Replies[j].Mentions = byPost[posts[i].Replies[j].ID]
+		}
+	}
+
+	return nil
+}
@@ -6,7 +6,7 @@ import (
 )
 
 type NotificationRepositoryInterface interface {
-	FindAll(userID uint64) ([]models.Notification, error)
+	FindAll(userID uint64, page models.PageRequest) (models.Page[models.Notification], error)
 	FindByID(userID, id uint64) (models.Notification, error)
 	CreateOrUpdate(notification models.Notification) error
 	Delete(userID, id uint64) error
@@ -22,23 +22,31 @@ func NewNotificationRepository(db *sql.DB) NotificationRepositoryInterface {
 	return &NotificationRepository{DB: db}
 }
 
-func (r *NotificationRepository) FindAll(userID uint64) ([]models.Notification, error) {
+// FindAll returns a page of a user's notifications, most recent first.
+func (r *NotificationRepository) FindAll(userID uint64, page models.PageRequest) (models.Page[models.Notification], error) {
 	var notifications []models.Notification
 
 	query := `SELECT DISTINCT notifications.*, users.name, users.username, users.avatar_url, posts.content AS post_content,
 		CASE
 			WHEN type = 'new_follower' THEN GREATEST((SELECT COUNT(*) FROM followers WHERE user_id = notifications.user_id) - 1, 0)
 			WHEN type = 'like' THEN GREATEST((SELECT COUNT(*) FROM likes WHERE post_id = notifications.source_post_id) - 1, 0)
+			WHEN type = 'repost' THEN GREATEST((SELECT COUNT(*) FROM reposts WHERE post_id = notifications.source_post_id) - 1, 0)
+			WHEN type = 'reply' THEN GREATEST((SELECT COUNT(DISTINCT author_id) FROM posts AS replies
+				WHERE (replies.parent_id = notifications.source_post_id OR replies.root_id = notifications.source_post_id)
+					AND replies.author_id <> notifications.user_id AND replies.deleted_at IS NULL) - 1, 0)
 			ELSE 0 END AS others_total
 		FROM notifications
 		LEFT JOIN users ON notifications.source_user_id = users.id
-		LEFT JOIN posts ON notifications.source_post_id = posts.id
+		LEFT JOIN posts ON notifications.source_post_id = posts.id AND posts.deleted_at IS NULL
 		WHERE notifications.user_id = $1
+			AND ($2::timestamptz IS NULL OR (notifications.created_at, notifications.id) < ($2::timestamptz, $3))
 		GROUP BY notifications.id, users.name, users.username, users.avatar_url, posts.content
-		ORDER BY created_at DESC`
-	rows, err := r.DB.Query(query, userID)
+		ORDER BY notifications.created_at DESC, notifications.id DESC
+		LIMIT $4`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, userID, after, afterID, page.Limit+1)
 	if err != nil {
-		return nil, err
+		return models.Page[models.Notification]{}, err
 	}
 	defer rows.Close()
 
@@ -60,13 +68,19 @@ func (r *NotificationRepository) FindAll(userID uint64) ([]models.Notification,
 			&notification.OthersTotal,
 		)
 		if err != nil {
-			return nil, err
+			return models.Page[models.Notification]{}, err
 		}
 
 		notifications = append(notifications, notification)
 	}
 
-	return notifications, nil
+	if err := rows.Err(); err != nil {
+		return models.Page[models.Notification]{}, err
+	}
+
+	return models.NewPage(notifications, page, func(i int) models.Cursor {
+		return models.TimeCursor(notifications[i].CreatedAt, notifications[i].ID)
+	}), nil
 }
 
 func (r *NotificationRepository) FindByID(userID, id uint64) (models.Notification, error) {
@@ -75,7 +89,7 @@ func (r *NotificationRepository) FindByID(userID, id uint64) (models.Notificatio
 	query := `SELECT notifications.*, users.name, users.username, users.avatar_url, posts.content AS post_content
 		FROM notifications
 		LEFT JOIN users ON notifications.source_user_id = users.id
-		LEFT JOIN posts ON notifications.source_post_id = posts.id
+		LEFT JOIN posts ON notifications.source_post_id = posts.id AND posts.deleted_at IS NULL
 		WHERE user_id = $1 AND notifications.id = $2`
 	row := r.DB.QueryRow(query, userID, id)
 
@@ -100,12 +114,14 @@ func (r *NotificationRepository) FindByID(userID, id uint64) (models.Notificatio
 	return notification, nil
 }
 
+// CreateOrUpdate groups notifications of the same type about the same post,
+// or about no post, into one that shows the latest source user.
 func (r *NotificationRepository) CreateOrUpdate(notification models.Notification) error {
 	result, err := r.DB.Exec(`
 			UPDATE notifications
 			SET source_user_id = $1, updated_at = CURRENT_TIMESTAMP, is_read = FALSE
-			WHERE user_id = $3 AND type = $2
-		`, notification.SourceUserID, notification.Type, notification.UserID)
+			WHERE user_id = $3 AND type = $2 AND source_post_id IS NOT DISTINCT FROM $4
+		`, notification.SourceUserID, notification.Type, notification.UserID, notification.SourcePostID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"project01/src/models"
+	"time"
+)
+
+var ErrTokenExpired = errors.New("token expired")
+
+type PasswordResetRepositoryInterface interface {
+	Create(reset *models.PasswordReset) error
+	Consume(tokenHash string) (*models.PasswordReset, error)
+}
+
+func NewPasswordResetRepository(db *sql.DB) PasswordResetRepositoryInterface {
+	return &PasswordResetRepository{DB: db}
+}
+
+type PasswordResetRepository struct {
+	DB *sql.DB
+}
+
+// Create stores a reset token, discarding any token still pending for the
+// same user.
+func (r *PasswordResetRepository) Create(reset *models.PasswordReset) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL`, reset.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id, created_at`
+	err = tx.QueryRow(query, reset.UserID, reset.TokenHash, reset.ExpiresAt).Scan(&reset.ID, &reset.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Consume marks an unused, unexpired reset token as used and returns it.
+func (r *PasswordResetRepository) Consume(tokenHash string) (*models.PasswordReset, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var reset models.PasswordReset
+	query := `SELECT id, user_id, expires_at, created_at FROM password_resets WHERE token_hash = $1 AND used_at IS NULL FOR UPDATE`
+	err = tx.QueryRow(query, tokenHash).Scan(&reset.ID, &reset.UserID, &reset.ExpiresAt, &reset.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if time.Now().After(reset.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	err = tx.QueryRow(`UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING used_at`, reset.ID).Scan(&reset.UsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &reset, nil
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"database/sql"
+	"project01/src/models"
+
+	"github.com/lib/pq"
+)
+
+type PersonalAccessTokenRepositoryInterface interface {
+	Create(token *models.PersonalAccessToken) error
+	FindAll(userID uint64) ([]models.PersonalAccessToken, error)
+	Revoke(userID, id uint64) error
+	RevokeAllForUser(userID uint64) error
+	Authenticate(tokenHash string) (*models.PersonalAccessToken, error)
+}
+
+func NewPersonalAccessTokenRepository(db *sql.DB) PersonalAccessTokenRepositoryInterface {
+	return &PersonalAccessTokenRepository{DB: db}
+}
+
+type PersonalAccessTokenRepository struct {
+	DB *sql.DB
+}
+
+func (r *PersonalAccessTokenRepository) Create(token *models.PersonalAccessToken) error {
+	query := `INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	return r.DB.QueryRow(query, token.UserID, token.Name, token.TokenHash, pq.Array(token.Scopes), token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+}
+
+// FindAll returns the user's tokens that have not been revoked.
+func (r *PersonalAccessTokenRepository) FindAll(userID uint64) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+
+	query := `SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var token models.PersonalAccessToken
+		if err := rows.Scan(
+			&token.ID,
+			&token.UserID,
+			&token.Name,
+			pq.Array(&token.Scopes),
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *PersonalAccessTokenRepository) Revoke(userID, id uint64) error {
+	query := `UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND id = $2 AND revoked_at IS NULL`
+	result, err := r.DB.Exec(query, userID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every token of a user.
+func (r *PersonalAccessTokenRepository) RevokeAllForUser(userID uint64) error {
+	_, err := r.DB.Exec(`UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate looks up a usable token by hash and records that it was used.
+func (r *PersonalAccessTokenRepository) Authenticate(tokenHash string) (*models.PersonalAccessToken, error) {
+	query := `UPDATE personal_access_tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		RETURNING id, user_id, name, scopes, expires_at, last_used_at, created_at`
+
+	var token models.PersonalAccessToken
+	err := r.DB.QueryRow(query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
@@ -2,19 +2,38 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"project01/src/models"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+var ErrEditWindowClosed = errors.New("post can no longer be edited")
+
 type PostRepositoryInterface interface {
 	Create(post *models.Post) (*models.Post, error)
-	FindByAuthorID(authorID uint64, currentUserID uint64) ([]models.Post, error)
+	FindByAuthorID(authorID uint64, currentUserID uint64, page models.PageRequest) (models.Page[models.Post], error)
 	FindByID(id uint64, currentUserID uint64) (*models.Post, error)
-	Update(post *models.Post) error
-	Delete(id uint64) error
+	Update(post *models.Post, editWindow time.Duration) (bool, error)
+	History(id uint64) ([]models.PostRevision, error)
+	Interactors(id uint64, limit int) ([]uint64, error)
+	Delete(id uint64, deletedBy string) error
+	Purge(retention time.Duration) (int64, error)
 	LikePost(postID, userID uint64) (bool, error)
 	UnlikePost(postID, userID uint64) error
-	LikesPost(postID uint64) ([]models.User, error)
-	PostsFollowedUsers(userID uint64) ([]models.Post, error)
+	LikesPost(postID uint64, page models.PageRequest) (models.Page[models.User], error)
+	PostsFollowedUsers(userID uint64, page models.PageRequest) (models.Page[models.Post], error)
+	Repost(postID, userID uint64) (uint64, bool, error)
+	Unrepost(postID, userID uint64) error
+	Reposts(postID uint64, page models.PageRequest) (models.Page[models.User], error)
+	FindWithoutReplies(id uint64, currentUserID uint64) (*models.Post, error)
+	Ancestors(id uint64, currentUserID uint64) ([]models.Post, error)
+	ReplyTree(id uint64, currentUserID uint64, depth int, page models.PageRequest) (models.Page[models.Post], error)
+	MuteConversation(userID, postID uint64) error
+	UnmuteConversation(userID, postID uint64) error
+	IsConversationMuted(userID, postID uint64) (bool, error)
+	IsVisible(postID, viewerID uint64) (bool, error)
 }
 
 func NewPostRepository(db *sql.DB) PostRepositoryInterface {
@@ -25,13 +44,72 @@ type PostRepository struct {
 	DB *sql.DB
 }
 
-// Create creates a new post in the database.
+// maxNestedReplies bounds how many replies below the first level ReplyTree
+// loads, however wide the conversation.
+const maxNestedReplies = 500
+
+// postColumns returns the columns every post query selects, in the order
+// scanPost reads them. currentUser is the placeholder of the viewer's ID.
+func postColumns(currentUser string) string {
+	return `posts.id, posts.parent_id, COALESCE(posts.author_id, 0), posts.content, posts.created_at,
+		COALESCE(users.name, '') AS author_name, COALESCE(users.username, ''), posts.like_count AS total_likes,
+		(SELECT EXISTS(SELECT 1 FROM likes WHERE post_id = posts.id AND user_id = ` + currentUser + `)) AS current_user_liked,
+		posts.reply_count AS total_replies, posts.repost_count AS total_reposts,
+		(SELECT EXISTS(SELECT 1 FROM reposts WHERE post_id = posts.id AND user_id = ` + currentUser + `)) AS current_user_reposted,
+		posts.quote_id, posts.root_id, posts.edited_at, posts.revision_count,
+		posts.deleted_at, COALESCE(posts.deleted_by, ''), posts.visibility`
+}
+
+// scanPost reads the columns of postColumns, followed by extra. Deleted
+// posts are turned into tombstones.
+func scanPost(row interface{ Scan(...interface{}) error }, post *models.Post, extra ...interface{}) error {
+	err := row.Scan(append([]interface{}{
+		&post.ID,
+		&post.ParentID,
+		&post.AuthorID,
+		&post.Content,
+		&post.CreatedAt,
+		&post.AuthorName,
+		&post.Username,
+		&post.TotalLikes,
+		&post.CurrentUserLiked,
+		&post.TotalReplies,
+		&post.TotalReposts,
+		&post.CurrentUserReposted,
+		&post.QuoteID,
+		&post.RootID,
+		&post.EditedAt,
+		&post.RevisionCount,
+		&post.DeletedAt,
+		&post.DeletedBy,
+		&post.Visibility,
+	}, extra...)...)
+	if err != nil {
+		return err
+	}
+
+	if post.DeletedAt != nil {
+		post.Tombstone()
+	}
+
+	return nil
+}
+
+// Create creates a new post in the database and updates the reply count of
+// its parent, or the post count of its author for a top-level post.
 func (r *PostRepository) Create(post *models.Post) (*models.Post, error) {
-	query := `INSERT INTO posts (content, author_id, parent_id) VALUES ($1, $2, $3) RETURNING id`
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO posts (content, author_id, parent_id, quote_id, root_id, visibility)
+		VALUES ($1, $2, $3, $4, (SELECT COALESCE(root_id, id) FROM posts WHERE id = $3), $5) RETURNING id`
 
 	var id uint64
 
-	err := r.DB.QueryRow(query, post.Content, post.AuthorID, post.ParentID).Scan(&id)
+	err = tx.QueryRow(query, post.Content, post.AuthorID, post.ParentID, post.QuoteID, post.Visibility).Scan(&id)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
@@ -40,78 +118,103 @@ func (r *PostRepository) Create(post *models.Post) (*models.Post, error) {
 		return nil, err
 	}
 
+	if len(post.MediaIDs) > 0 {
+		mediaIDs := make([]int64, len(post.MediaIDs))
+		for i, mediaID := range post.MediaIDs {
+			mediaIDs[i] = int64(mediaID)
+		}
+
+		query = `UPDATE media_attachments SET post_id = $1, position = array_position($3::bigint[], id::bigint)
+			WHERE id = ANY($3::bigint[]) AND user_id = $2 AND post_id IS NULL`
+		result, err := tx.Exec(query, id, post.AuthorID, pq.Array(mediaIDs))
+		if err != nil {
+			return nil, err
+		}
+
+		attached, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		if attached != int64(len(mediaIDs)) {
+			return nil, ErrMediaUnavailable
+		}
+	}
+
+	if err = syncTags(tx, id, post.Content); err != nil {
+		return nil, err
+	}
+
+	if err = syncMentions(tx, id, post.Content); err != nil {
+		return nil, err
+	}
+
+	if post.ParentID != nil {
+		_, err = tx.Exec(`UPDATE posts SET reply_count = reply_count + 1 WHERE id = $1`, *post.ParentID)
+	} else {
+		_, err = tx.Exec(`UPDATE users SET post_count = post_count + 1 WHERE id = $1`, post.AuthorID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return r.FindByID(id, post.AuthorID)
 }
 
-// FindByAuthorID retrieves posts by the author's ID from the database.
-func (r *PostRepository) FindByAuthorID(authorID uint64, currentUserID uint64) ([]models.Post, error) {
+// FindByAuthorID retrieves a page of posts by the author's ID from the
+// database, leaving out those the current user cannot see.
+func (r *PostRepository) FindByAuthorID(authorID uint64, currentUserID uint64, page models.PageRequest) (models.Page[models.Post], error) {
 	var posts []models.Post
 
-	query := `SELECT posts.*, users.name AS author_name, users.username, COUNT(likes.id) AS total_likes,
-		(SELECT EXISTS(SELECT 1 FROM likes WHERE post_id = posts.id AND user_id = $2)) AS current_user_liked,
-		(SELECT COUNT(*) FROM posts AS replies WHERE replies.parent_id = posts.id) AS total_replies
+	query := `SELECT ` + postColumns("$2") + `
 		FROM posts
 		LEFT JOIN users ON users.id = posts.author_id
-		LEFT JOIN likes ON likes.post_id = posts.id
-		WHERE posts.author_id = $1 AND posts.parent_id IS NULL
-		GROUP BY posts.id, users.name, users.username
-		ORDER BY posts.created_at DESC`
-	rows, err := r.DB.Query(query, authorID, currentUserID)
+		WHERE posts.author_id = $1 AND posts.parent_id IS NULL AND posts.deleted_at IS NULL AND ` + visibleTo("$2") + `
+			AND ($3::timestamp IS NULL OR (posts.created_at, posts.id) < ($3::timestamp, $4))
+		ORDER BY posts.created_at DESC, posts.id DESC
+		LIMIT $5`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, authorID, currentUserID, after, afterID, page.Limit+1)
 	if err != nil {
-		return nil, err
+		return models.Page[models.Post]{}, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var post models.Post
-		if err := rows.Scan(
-			&post.ID,
-			&post.ParentID,
-			&post.AuthorID,
-			&post.Content,
-			&post.CreatedAt,
-			&post.AuthorName,
-			&post.Username,
-			&post.TotalLikes,
-			&post.CurrentUserLiked,
-			&post.TotalReplies,
-		); err != nil {
-			return nil, err
+		if err := scanPost(rows, &post); err != nil {
+			return models.Page[models.Post]{}, err
 		}
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return models.Page[models.Post]{}, err
 	}
 
-	return posts, nil
+	if err := r.loadRelated(posts, currentUserID); err != nil {
+		return models.Page[models.Post]{}, err
+	}
+
+	return models.NewPage(posts, page, func(i int) models.Cursor {
+		return models.TimeCursor(posts[i].CreatedAt, posts[i].ID)
+	}), nil
 }
 
-// FindByID retrieves a post by its ID from the database.
+// FindByID retrieves a post by its ID from the database. A post the current
+// user cannot see is reported as not found.
 func (r *PostRepository) FindByID(id uint64, currentUserID uint64) (*models.Post, error) {
 	var post models.Post
 
-	query := `SELECT posts.*, users.name AS author_name, users.username, COUNT(likes.id) AS total_likes,
-		(SELECT EXISTS(SELECT 1 FROM likes WHERE post_id = posts.id AND user_id = $2)) AS current_user_liked,
-		(SELECT COUNT(*) FROM posts AS replies WHERE replies.parent_id = posts.id) AS total_replies
+	query := `SELECT ` + postColumns("$2") + `
 		FROM posts
 		LEFT JOIN users ON users.id = posts.author_id
-		LEFT JOIN likes ON likes.post_id = posts.id
-		WHERE posts.id = $1
-		GROUP BY posts.id, users.name, users.username`
-	err := r.DB.QueryRow(query, id, currentUserID).Scan(
-		&post.ID,
-		&post.ParentID,
-		&post.AuthorID,
-		&post.Content,
-		&post.CreatedAt,
-		&post.AuthorName,
-		&post.Username,
-		&post.TotalLikes,
-		&post.CurrentUserLiked,
-		&post.TotalReplies,
-	)
+		WHERE posts.id = $1 AND posts.deleted_at IS NULL AND ` + visibleTo("$2") + ``
+	err := scanPost(r.DB.QueryRow(query, id, currentUserID), &post)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -124,57 +227,241 @@ func (r *PostRepository) FindByID(id uint64, currentUserID uint64) (*models.Post
 		return nil, err
 	}
 
+	posts := []models.Post{post}
+	if err = r.loadRelated(posts, currentUserID); err != nil {
+		return nil, err
+	}
+	post = posts[0]
+
 	return &post, nil
 }
 
-// Update updates the content of a post in the database.
-func (r *PostRepository) Update(post *models.Post) error {
-	query := `UPDATE posts SET content = $1 WHERE id = $2`
-	result, err := r.DB.Exec(query, post.Content, post.ID)
+// Update updates the content of a post in the database, keeping the content
+// it replaces as a revision. It reports whether the content changed, and
+// refuses changes once editWindow has passed since the post was created.
+func (r *PostRepository) Update(post *models.Post, editWindow time.Duration) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT content, COALESCE(edited_at, created_at),
+			created_at < CURRENT_TIMESTAMP::timestamp - $2 * INTERVAL '1 second'
+		FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
 
+	var content string
+	var publishedAt time.Time
+	var windowClosed bool
+	err = tx.QueryRow(query, post.ID, editWindow.Seconds()).Scan(&content, &publishedAt, &windowClosed)
 	if err != nil {
-		return err
+		if err == sql.ErrNoRows {
+			return false, ErrNotFound
+		}
+		return false, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if content == post.Content {
+		return false, nil
+	}
+
+	if windowClosed {
+		return false, ErrEditWindowClosed
+	}
+
+	query = `INSERT INTO post_revisions (post_id, content, created_at) VALUES ($1, $2, $3)`
+	if _, err = tx.Exec(query, post.ID, content, publishedAt); err != nil {
+		return false, err
+	}
+
+	query = `UPDATE posts SET content = $1, edited_at = CURRENT_TIMESTAMP, revision_count = revision_count + 1
+		WHERE id = $2`
+	if _, err = tx.Exec(query, post.Content, post.ID); err != nil {
+		return false, err
+	}
+
+	if err = syncTags(tx, post.ID, post.Content); err != nil {
+		return false, err
+	}
+
+	if err = syncMentions(tx, post.ID, post.Content); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// History returns every version of a post's content, the current one first.
+func (r *PostRepository) History(id uint64) ([]models.PostRevision, error) {
+	query := `SELECT content, created_at FROM (
+			SELECT content, COALESCE(edited_at, created_at) AS created_at, TRUE AS current, id FROM posts
+				WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT post_revisions.content, post_revisions.created_at, FALSE, post_revisions.id FROM post_revisions
+				JOIN posts ON posts.id = post_revisions.post_id AND posts.deleted_at IS NULL
+				WHERE post_revisions.post_id = $1
+		) AS versions
+		ORDER BY current DESC, created_at DESC, id DESC`
+	rows, err := r.DB.Query(query, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	if rowsAffected == 0 {
-		return ErrNotFound
+	var revisions []models.PostRevision
+	for rows.Next() {
+		var revision models.PostRevision
+		if err := rows.Scan(&revision.Content, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
 	}
 
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return revisions, nil
+}
+
+// Interactors returns up to limit users who liked, reposted, quoted or
+// replied to a post, other than its author.
+func (r *PostRepository) Interactors(id uint64, limit int) ([]uint64, error) {
+	query := `SELECT user_id FROM (
+			SELECT user_id FROM likes WHERE post_id = $1
+			UNION SELECT user_id FROM reposts WHERE post_id = $1
+			UNION SELECT author_id FROM posts WHERE (parent_id = $1 OR quote_id = $1) AND author_id IS NOT NULL
+		) AS interactions
+		WHERE user_id <> (SELECT author_id FROM posts WHERE id = $1)
+		LIMIT $2`
+	rows, err := r.DB.Query(query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []uint64
+	for rows.Next() {
+		var userID uint64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
 }
 
-// Delete deletes a post from the database.
-func (r *PostRepository) Delete(id uint64) error {
-	query := `DELETE FROM posts WHERE id = $1`
-	result, err := r.DB.Exec(query, id)
+// Delete marks a post as deleted by its "author" or a "moderator", takes it
+// out of timelines and updates the counters Create incremented. The post
+// stays as a tombstone in its thread until Purge erases it.
+func (r *PostRepository) Delete(id uint64, deletedBy string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	query := `UPDATE posts SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING parent_id, author_id`
+
+	var parentID *uint64
+	var authorID uint64
+	err = tx.QueryRow(query, id, deletedBy).Scan(&parentID, &authorID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if parentID != nil {
+		_, err = tx.Exec(`UPDATE posts SET reply_count = GREATEST(reply_count - 1, 0) WHERE id = $1`, *parentID)
+	} else {
+		_, err = tx.Exec(`UPDATE users SET post_count = GREATEST(post_count - 1, 0) WHERE id = $1`, authorID)
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrNotFound
+	if _, err = tx.Exec(`DELETE FROM timelines WHERE post_id = $1`, id); err != nil {
+		return err
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// Purge erases posts deleted more than retention ago. Tombstones without
+// replies are removed altogether; the others keep their place in their
+// thread but lose their content, revisions, tags, mentions and media. The
+// stored media files are then collected by media.CollectGarbage. It returns
+// how many posts it removed or erased.
+func (r *PostRepository) Purge(retention time.Duration) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	const expired = `deleted_at < CURRENT_TIMESTAMP::timestamp - $1 * INTERVAL '1 second'`
+
+	query := `DELETE FROM posts WHERE ` + expired + `
+		AND NOT EXISTS(SELECT 1 FROM posts AS replies WHERE replies.parent_id = posts.id)`
+	result, err := tx.Exec(query, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, table := range []string{"post_revisions", "post_tags", "post_mentions", "media_attachments"} {
+		query = `DELETE FROM ` + table + ` WHERE post_id IN (SELECT id FROM posts WHERE ` + expired + `)`
+		if _, err = tx.Exec(query, retention.Seconds()); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err = tx.Exec(`UPDATE posts SET content = '' WHERE `+expired+` AND content <> ''`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	erased, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return removed + erased, nil
 }
 
 // LikePost adds a like to a post in the database.
 func (r *PostRepository) LikePost(postID, userID uint64) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO likes (post_id, user_id) VALUES ($1, $2) ON CONFLICT (post_id, user_id) DO NOTHING RETURNING id`
 	var id uint64
 
-	err := r.DB.QueryRow(query, postID, userID).Scan(&id)
+	err = tx.QueryRow(query, postID, userID).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -183,60 +470,344 @@ func (r *PostRepository) LikePost(postID, userID uint64) (bool, error) {
 		return false, err
 	}
 
+	if _, err = tx.Exec(`UPDATE posts SET like_count = like_count + 1 WHERE id = $1`, postID); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
 // UnlikePost removes a like from a post in the database.
 func (r *PostRepository) UnlikePost(postID, userID uint64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `DELETE FROM likes WHERE post_id = $1 AND user_id = $2`
-	_, err := r.DB.Exec(query, postID, userID)
+	result, err := tx.Exec(query, postID, userID)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		_, err = tx.Exec(`UPDATE posts SET like_count = GREATEST(like_count - 1, 0) WHERE id = $1`, postID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-// LikesPost retrieves the users who liked a post from the database.
-func (r *PostRepository) LikesPost(postID uint64) ([]models.User, error) {
+// LikesPost retrieves a page of the users who liked a post from the database,
+// most recent likes first.
+func (r *PostRepository) LikesPost(postID uint64, page models.PageRequest) (models.Page[models.User], error) {
 	var users []models.User
+	var cursors []models.Cursor
 
-	query := `SELECT users.id, name, username, avatar_url, bio, users.created_at FROM likes LEFT JOIN users ON users.id = likes.user_id WHERE likes.post_id = $1 ORDER BY likes.id`
-	rows, err := r.DB.Query(query, postID)
+	query := `SELECT users.id, name, username, avatar_url, bio, users.created_at, likes.id, likes.created_at
+		FROM likes
+		LEFT JOIN users ON users.id = likes.user_id
+		WHERE likes.post_id = $1
+			AND ($2::timestamp IS NULL OR (likes.created_at, likes.id) < ($2::timestamp, $3))
+		ORDER BY likes.created_at DESC, likes.id DESC
+		LIMIT $4`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, postID, after, afterID, page.Limit+1)
 	if err != nil {
-		return nil, err
+		return models.Page[models.User]{}, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.AvatarURL, &user.Bio, &user.CreatedAt); err != nil {
-			return nil, err
+		var likeID uint64
+		var likedAt time.Time
+		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.AvatarURL, &user.Bio, &user.CreatedAt, &likeID, &likedAt); err != nil {
+			return models.Page[models.User]{}, err
 		}
 		users = append(users, user)
+		cursors = append(cursors, models.TimeCursor(likedAt, likeID))
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return models.Page[models.User]{}, err
 	}
 
-	return users, nil
+	return models.NewPage(users, page, func(i int) models.Cursor {
+		return cursors[i]
+	}), nil
 }
 
-// PostsFollowedUsers retrieves posts from the users that the current user is following.
-func (r *PostRepository) PostsFollowedUsers(userID uint64) ([]models.Post, error) {
+// PostsFollowedUsers retrieves a page of the home timeline: the posts and
+// reposts fanned out to the user's timeline, merged with those of followed
+// users that are too large to fan out and with posts tagged with followed
+// tags. A post that comes from several of these shows up once, at its latest
+// appearance: later pages leave out posts with an appearance no older than the
+// cursor, since those were already on an earlier page. Each source only
+// yields posts the user can see, so that filtering never leaves a page short.
+func (r *PostRepository) PostsFollowedUsers(userID uint64, page models.PageRequest) (models.Page[models.Post], error) {
 	var posts []models.Post
+	var cursors []models.Cursor
 
-	query := `SELECT posts.*, users.name AS author_name, users.username, COUNT(likes.id) AS total_likes,
-		(SELECT EXISTS(SELECT 1 FROM likes WHERE post_id = posts.id AND user_id = $1)) AS current_user_liked,
-		(SELECT COUNT(*) FROM posts AS replies WHERE replies.parent_id = posts.id) AS total_replies
-		FROM posts
+	query := `SELECT ` + postColumns("$1") + `,
+		feed.created_at, reposts.user_id, reposters.name, reposters.username, reposts.created_at
+		FROM (
+			SELECT DISTINCT ON (post_id) post_id, created_at, repost_id FROM (
+				(SELECT timelines.post_id, timelines.created_at, timelines.repost_id FROM timelines
+					JOIN posts ON posts.id = timelines.post_id AND posts.deleted_at IS NULL AND ` + visibleTo("$1") + `
+					WHERE timelines.user_id = $1
+						AND ($2::timestamp IS NULL OR (timelines.created_at, timelines.post_id) < ($2::timestamp, $3))
+					ORDER BY timelines.created_at DESC, timelines.post_id DESC
+					LIMIT $4)
+				UNION ALL
+				(SELECT posts.id AS post_id, posts.created_at, NULL AS repost_id FROM posts
+					JOIN users AS authors ON authors.id = posts.author_id AND authors.fan_out_on_read
+					JOIN followers ON followers.user_id = posts.author_id AND followers.follower_id = $1
+					WHERE posts.parent_id IS NULL AND posts.deleted_at IS NULL AND ` + visibleTo("$1") + `
+						AND ($2::timestamp IS NULL OR (posts.created_at, posts.id) < ($2::timestamp, $3))
+					ORDER BY posts.created_at DESC, posts.id DESC
+					LIMIT $4)
+				UNION ALL
+				(SELECT post_tags.post_id, post_tags.created_at, NULL AS repost_id FROM post_tags
+					JOIN tag_follows ON tag_follows.tag_id = post_tags.tag_id AND tag_follows.user_id = $1
+					JOIN posts ON posts.id = post_tags.post_id AND posts.parent_id IS NULL
+						AND posts.deleted_at IS NULL AND ` + visibleTo("$1") + `
+					WHERE ($2::timestamp IS NULL OR (post_tags.created_at, post_tags.post_id) < ($2::timestamp, $3))
+					ORDER BY post_tags.created_at DESC, post_tags.post_id DESC
+					LIMIT $4)
+				UNION ALL
+				(SELECT reposts.post_id, reposts.created_at, reposts.id AS repost_id FROM reposts
+					JOIN posts ON posts.id = reposts.post_id AND posts.author_id <> $1
+						AND posts.deleted_at IS NULL AND ` + visibleTo("$1") + `
+					JOIN users AS reposters ON reposters.id = reposts.user_id AND reposters.fan_out_on_read
+					JOIN followers ON followers.user_id = reposts.user_id AND followers.follower_id = $1
+					WHERE ($2::timestamp IS NULL OR (reposts.created_at, reposts.post_id) < ($2::timestamp, $3))
+					ORDER BY reposts.created_at DESC, reposts.post_id DESC
+					LIMIT $4)
+			) AS candidates
+			ORDER BY post_id, created_at DESC
+		) AS feed
+		JOIN posts ON posts.id = feed.post_id
+		WHERE $2::timestamp IS NULL OR NOT EXISTS (
+			SELECT 1 FROM timelines
+				WHERE timelines.user_id = $1 AND timelines.post_id = feed.post_id
+					AND (timelines.created_at, timelines.post_id) >= ($2::timestamp, $3)
+			UNION ALL
+			SELECT 1 FROM posts AS shown
+				JOIN users AS authors ON authors.id = shown.author_id AND authors.fan_out_on_read
+				JOIN followers ON followers.user_id = shown.author_id AND followers.follower_id = $1
+				WHERE shown.id = feed.post_id AND shown.parent_id IS NULL
+					AND (shown.created_at, shown.id) >= ($2::timestamp, $3)
+			UNION ALL
+			SELECT 1 FROM post_tags
+				JOIN tag_follows ON tag_follows.tag_id = post_tags.tag_id AND tag_follows.user_id = $1
+				WHERE post_tags.post_id = feed.post_id AND posts.parent_id IS NULL
+					AND (post_tags.created_at, post_tags.post_id) >= ($2::timestamp, $3)
+			UNION ALL
+			SELECT 1 FROM reposts
+				JOIN users AS reposters ON reposters.id = reposts.user_id AND reposters.fan_out_on_read
+				JOIN followers ON followers.user_id = reposts.user_id AND followers.follower_id = $1
+				WHERE reposts.post_id = feed.post_id AND posts.author_id <> $1
+					AND (reposts.created_at, reposts.post_id) >= ($2::timestamp, $3)
+		)
 		LEFT JOIN users ON users.id = posts.author_id
-		LEFT JOIN likes ON likes.post_id = posts.id
-		WHERE posts.author_id IN (SELECT user_id FROM followers WHERE follower_id = $1) AND posts.parent_id IS NULL
-		GROUP BY posts.id, users.name, users.username
-		ORDER BY posts.created_at DESC`
-	rows, err := r.DB.Query(query, userID)
+		LEFT JOIN reposts ON reposts.id = feed.repost_id
+		LEFT JOIN users AS reposters ON reposters.id = reposts.user_id
+		ORDER BY feed.created_at DESC, feed.post_id DESC
+		LIMIT $4`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, userID, after, afterID, page.Limit+1)
+	if err != nil {
+		return models.Page[models.Post]{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post models.Post
+		var feedAt time.Time
+		var reposterID sql.NullInt64
+		var reposterName, reposterUsername sql.NullString
+		var repostedAt sql.NullTime
+		if err := scanPost(rows, &post, &feedAt, &reposterID, &reposterName, &reposterUsername, &repostedAt); err != nil {
+			return models.Page[models.Post]{}, err
+		}
+
+		if reposterID.Valid {
+			post.RepostedBy = &models.Repost{
+				UserID:    uint64(reposterID.Int64),
+				Name:      reposterName.String,
+				Username:  reposterUsername.String,
+				CreatedAt: repostedAt.Time,
+			}
+		}
+
+		posts = append(posts, post)
+		cursors = append(cursors, models.TimeCursor(feedAt, post.ID))
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.Post]{}, err
+	}
+
+	if err := r.loadRelated(posts, userID); err != nil {
+		return models.Page[models.Post]{}, err
+	}
+
+	return models.NewPage(posts, page, func(i int) models.Cursor {
+		return cursors[i]
+	}), nil
+}
+
+// Repost shares a post with the user's followers and updates its repost
+// count. It returns the ID of the repost and whether it is new.
+func (r *PostRepository) Repost(postID, userID uint64) (uint64, bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO reposts (post_id, user_id) VALUES ($1, $2) ON CONFLICT (post_id, user_id) DO NOTHING RETURNING id`
+	var id uint64
+
+	err = tx.QueryRow(query, postID, userID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+
+		return 0, false, err
+	}
+
+	if _, err = tx.Exec(`UPDATE posts SET repost_count = repost_count + 1 WHERE id = $1`, postID); err != nil {
+		return 0, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// Unrepost undoes a repost, which also takes it out of timelines.
+func (r *PostRepository) Unrepost(postID, userID uint64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM reposts WHERE post_id = $1 AND user_id = $2`
+	result, err := tx.Exec(query, postID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		_, err = tx.Exec(`UPDATE posts SET repost_count = GREATEST(repost_count - 1, 0) WHERE id = $1`, postID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Reposts retrieves a page of the users who reposted a post, most recent
+// reposts first.
+func (r *PostRepository) Reposts(postID uint64, page models.PageRequest) (models.Page[models.User], error) {
+	var users []models.User
+	var cursors []models.Cursor
+
+	query := `SELECT users.id, name, username, avatar_url, bio, users.created_at, reposts.id, reposts.created_at
+		FROM reposts
+		JOIN users ON users.id = reposts.user_id
+		WHERE reposts.post_id = $1
+			AND ($2::timestamp IS NULL OR (reposts.created_at, reposts.id) < ($2::timestamp, $3))
+		ORDER BY reposts.created_at DESC, reposts.id DESC
+		LIMIT $4`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, postID, after, afterID, page.Limit+1)
+	if err != nil {
+		return models.Page[models.User]{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		var repostID uint64
+		var repostedAt time.Time
+		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.AvatarURL, &user.Bio, &user.CreatedAt, &repostID, &repostedAt); err != nil {
+			return models.Page[models.User]{}, err
+		}
+		users = append(users, user)
+		cursors = append(cursors, models.TimeCursor(repostedAt, repostID))
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.User]{}, err
+	}
+
+	return models.NewPage(users, page, func(i int) models.Cursor {
+		return cursors[i]
+	}), nil
+}
+
+// FindWithoutReplies retrieves a post like FindByID, without its replies.
+func (r *PostRepository) FindWithoutReplies(id uint64, currentUserID uint64) (*models.Post, error) {
+	posts, err := r.findByIDs([]int64{int64(id)}, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(posts) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if err = r.loadRelated(posts, currentUserID); err != nil {
+		return nil, err
+	}
+
+	return &posts[0], nil
+}
+
+// Ancestors retrieves the posts a reply answers that the current user can
+// see, from the top-level post down to its parent.
+func (r *PostRepository) Ancestors(id uint64, currentUserID uint64) ([]models.Post, error) {
+	posts := []models.Post{}
+
+	query := `WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id, 1 AS depth FROM posts WHERE id = $1 AND parent_id IS NOT NULL
+			UNION ALL
+			SELECT posts.parent_id, ancestors.depth + 1
+			FROM posts
+			JOIN ancestors ON posts.id = ancestors.id
+			WHERE posts.parent_id IS NOT NULL
+		)
+		SELECT ` + postColumns("$2") + `
+		FROM ancestors
+		JOIN posts ON posts.id = ancestors.id AND ` + visibleTo("$2") + `
+		LEFT JOIN users ON users.id = posts.author_id
+		ORDER BY ancestors.depth DESC`
+	rows, err := r.DB.Query(query, id, currentUserID)
 	if err != nil {
 		return nil, err
 	}
@@ -244,18 +815,7 @@ func (r *PostRepository) PostsFollowedUsers(userID uint64) ([]models.Post, error
 
 	for rows.Next() {
 		var post models.Post
-		if err := rows.Scan(
-			&post.ID,
-			&post.ParentID,
-			&post.AuthorID,
-			&post.Content,
-			&post.CreatedAt,
-			&post.AuthorName,
-			&post.Username,
-			&post.TotalLikes,
-			&post.CurrentUserLiked,
-			&post.TotalReplies,
-		); err != nil {
+		if err := scanPost(rows, &post); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
@@ -265,21 +825,172 @@ func (r *PostRepository) PostsFollowedUsers(userID uint64) ([]models.Post, error
 		return nil, err
 	}
 
+	if err := r.loadRelated(posts, currentUserID); err != nil {
+		return nil, err
+	}
+
 	return posts, nil
 }
 
-// findRepliesByParentID retrieves the replies to a post from the database.
+// ReplyTree retrieves a page of the direct replies to a post, oldest first,
+// each with its own replies nested in Replies down to depth levels in all.
+// Replies deeper than that are left out; clients can tell from
+// total_replies and fetch them with another call.
+func (r *PostRepository) ReplyTree(id uint64, currentUserID uint64, depth int, page models.PageRequest) (models.Page[models.Post], error) {
+	var replies []models.Post
+
+	query := `SELECT ` + postColumns("$2") + `
+		FROM posts
+		LEFT JOIN users ON users.id = posts.author_id
+		WHERE posts.parent_id = $1 AND ` + visibleTo("$2") + `
+			AND ($3::timestamp IS NULL OR (posts.created_at, posts.id) > ($3::timestamp, $4))
+		ORDER BY posts.created_at ASC, posts.id ASC
+		LIMIT $5`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, id, currentUserID, after, afterID, page.Limit+1)
+	if err != nil {
+		return models.Page[models.Post]{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post models.Post
+		if err := scanPost(rows, &post); err != nil {
+			return models.Page[models.Post]{}, err
+		}
+		replies = append(replies, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.Post]{}, err
+	}
+
+	result := models.NewPage(replies, page, func(i int) models.Cursor {
+		return models.TimeCursor(replies[i].CreatedAt, replies[i].ID)
+	})
+
+	if err := r.loadRelated(result.Data, currentUserID); err != nil {
+		return models.Page[models.Post]{}, err
+	}
+
+	if len(result.Data) > 0 && depth > 1 {
+		if err := r.nestReplies(result.Data, currentUserID, depth-1); err != nil {
+			return models.Page[models.Post]{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// nestReplies fills in the replies of posts, oldest first, down to depth
+// levels, loading at most maxNestedReplies of them.
+func (r *PostRepository) nestReplies(posts []models.Post, currentUserID uint64, depth int) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = int64(post.ID)
+	}
+
+	query := `WITH RECURSIVE descendants AS (
+			SELECT id, 1 AS depth FROM posts WHERE parent_id = ANY($1)
+			UNION ALL
+			SELECT posts.id, descendants.depth + 1
+			FROM posts
+			JOIN descendants ON posts.parent_id = descendants.id
+			WHERE descendants.depth < $3
+		)
+		SELECT ` + postColumns("$2") + `
+		FROM descendants
+		JOIN posts ON posts.id = descendants.id AND ` + visibleTo("$2") + `
+		LEFT JOIN users ON users.id = posts.author_id
+		ORDER BY descendants.depth, posts.created_at, posts.id
+		LIMIT $4`
+	rows, err := r.DB.Query(query, pq.Array(ids), currentUserID, depth, maxNestedReplies)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var descendants []models.Post
+	for rows.Next() {
+		var post models.Post
+		if err := scanPost(rows, &post); err != nil {
+			return err
+		}
+		descendants = append(descendants, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := r.loadRelated(descendants, currentUserID); err != nil {
+		return err
+	}
+
+	children := map[uint64][]models.Post{}
+	for _, post := range descendants {
+		children[*post.ParentID] = append(children[*post.ParentID], post)
+	}
+
+	var nest func(posts []models.Post)
+	nest = func(posts []models.Post) {
+		for i := range posts {
+			posts[i].Replies = children[posts[i].ID]
+			nest(posts[i].Replies)
+		}
+	}
+	nest(posts)
+
+	return nil
+}
+
+// MuteConversation stops reply and mention notifications to userID from the
+// conversation postID belongs to.
+func (r *PostRepository) MuteConversation(userID, postID uint64) error {
+	query := `INSERT INTO conversation_mutes (user_id, root_id)
+		SELECT $1, COALESCE(root_id, id) FROM posts WHERE id = $2
+		ON CONFLICT DO NOTHING`
+	_, err := r.DB.Exec(query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *PostRepository) UnmuteConversation(userID, postID uint64) error {
+	query := `DELETE FROM conversation_mutes
+		WHERE user_id = $1 AND root_id = (SELECT COALESCE(root_id, id) FROM posts WHERE id = $2)`
+	_, err := r.DB.Exec(query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IsConversationMuted reports whether userID muted the conversation postID
+// belongs to.
+func (r *PostRepository) IsConversationMuted(userID, postID uint64) (bool, error) {
+	var muted bool
+	query := `SELECT EXISTS(SELECT 1 FROM conversation_mutes
+		WHERE user_id = $1 AND root_id = (SELECT COALESCE(root_id, id) FROM posts WHERE id = $2))`
+	if err := r.DB.QueryRow(query, userID, postID).Scan(&muted); err != nil {
+		return false, err
+	}
+
+	return muted, nil
+}
+
+// findRepliesByParentID retrieves the replies to a post that the current user
+// can see from the database.
 func (r *PostRepository) findRepliesByParentID(parentID uint64, currentUserID uint64) ([]models.Post, error) {
 	var posts []models.Post
 
-	query := `SELECT posts.*, users.name AS author_name, users.username, COUNT(likes.id) AS total_likes,
-		(SELECT EXISTS(SELECT 1 FROM likes WHERE post_id = posts.id AND user_id = $2)) AS current_user_liked,
-		(SELECT COUNT(*) FROM posts AS replies WHERE replies.parent_id = posts.id) AS total_replies
+	query := `SELECT ` + postColumns("$2") + `
 		FROM posts
 		LEFT JOIN users ON users.id = posts.author_id
-		LEFT JOIN likes ON likes.post_id = posts.id
-		WHERE posts.parent_id = $1
-		GROUP BY posts.id, users.name, users.username
+		WHERE posts.parent_id = $1 AND ` + visibleTo("$2") + `
 		ORDER BY total_likes DESC, posts.created_at ASC`
 	rows, err := r.DB.Query(query, parentID, currentUserID)
 	if err != nil {
@@ -289,18 +1000,105 @@ func (r *PostRepository) findRepliesByParentID(parentID uint64, currentUserID ui
 
 	for rows.Next() {
 		var post models.Post
-		if err := rows.Scan(
-			&post.ID,
-			&post.ParentID,
-			&post.AuthorID,
-			&post.Content,
-			&post.CreatedAt,
-			&post.AuthorName,
-			&post.Username,
-			&post.TotalLikes,
-			&post.CurrentUserLiked,
-			&post.TotalReplies,
-		); err != nil {
+		if err := scanPost(rows, &post); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// loadRelated fills in the quoted posts, media attachments and mentions of
+// posts and their replies.
+func (r *PostRepository) loadRelated(posts []models.Post, currentUserID uint64) error {
+	var quoteIDs []int64
+	for _, post := range posts {
+		if post.QuoteID != nil {
+			quoteIDs = append(quoteIDs, int64(*post.QuoteID))
+		}
+		for _, reply := range post.Replies {
+			if reply.QuoteID != nil {
+				quoteIDs = append(quoteIDs, int64(*reply.QuoteID))
+			}
+		}
+	}
+
+	if len(quoteIDs) > 0 {
+		quotes, err := r.findByIDs(quoteIDs, currentUserID)
+		if err != nil {
+			return err
+		}
+
+		if err = attachMedia(r.DB, quotes); err != nil {
+			return err
+		}
+
+		if err = attachMentions(r.DB, quotes); err != nil {
+			return err
+		}
+
+		byID := make(map[uint64]*models.Post, len(quotes))
+		for i := range quotes {
+			byID[quotes[i].ID] = &quotes[i]
+		}
+
+		for i := range posts {
+			if posts[i].QuoteID != nil {
+				posts[i].Quote = byID[*posts[i].QuoteID]
+			}
+			for j := range posts[i].Replies {
+				if posts[i].Replies[j].QuoteID != nil {
+					posts[i].Replies[j].Quote = byID[*posts[i].Replies[j].QuoteID]
+				}
+			}
+		}
+	}
+
+	if err := attachMedia(r.DB, posts); err != nil {
+		return err
+	}
+
+	if err := attachMentions(r.DB, posts); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		if posts[i].DeletedAt != nil {
+			posts[i].Tombstone()
+		}
+		for j := range posts[i].Replies {
+			if posts[i].Replies[j].DeletedAt != nil {
+				posts[i].Replies[j].Tombstone()
+			}
+		}
+	}
+
+	return nil
+}
+
+// findByIDs retrieves the posts with the given IDs that the current user can
+// see, in no particular order.
+func (r *PostRepository) findByIDs(ids []int64, currentUserID uint64) ([]models.Post, error) {
+	var posts []models.Post
+
+	query := `SELECT ` + postColumns("$2") + `
+		FROM posts
+		LEFT JOIN users ON users.id = posts.author_id
+		WHERE posts.id = ANY($1) AND posts.deleted_at IS NULL AND ` + visibleTo("$2") + ``
+	rows, err := r.DB.Query(query, pq.Array(ids), currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post models.Post
+		if err := scanPost(rows, &post); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"project01/src/models"
+	"time"
+)
+
+var (
+	ErrSessionRevoked = errors.New("session revoked")
+	ErrSessionExpired = errors.New("session expired")
+	ErrTokenReused    = errors.New("refresh token reused")
+)
+
+type SessionRepositoryInterface interface {
+	Create(session *models.Session) error
+	Rotate(tokenHash string, newTokenHash string, expiresAt time.Time) (*models.Session, error)
+	IsActive(familyID string) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeAllForUser(userID uint64) error
+	RevokeOthers(userID uint64, familyID string) error
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepositoryInterface {
+	return &SessionRepository{DB: db}
+}
+
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+// Create stores a new refresh token.
+func (r *SessionRepository) Create(session *models.Session) error {
+	query := `INSERT INTO sessions (family_id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+
+	return r.DB.QueryRow(query, session.FamilyID, session.UserID, session.TokenHash, session.ExpiresAt).
+		Scan(&session.ID, &session.CreatedAt)
+}
+
+// Rotate exchanges the refresh token identified by tokenHash for a new one in
+// the same family. Presenting a token that was already rotated is treated as
+// theft and revokes the whole family.
+func (r *SessionRepository) Rotate(tokenHash string, newTokenHash string, expiresAt time.Time) (*models.Session, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current models.Session
+	query := `SELECT id, family_id, user_id, expires_at, rotated_at, revoked_at FROM sessions WHERE token_hash = $1 FOR UPDATE`
+	err = tx.QueryRow(query, tokenHash).Scan(
+		&current.ID,
+		&current.FamilyID,
+		&current.UserID,
+		&current.ExpiresAt,
+		&current.RotatedAt,
+		&current.RevokedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if current.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+
+	if current.RotatedAt != nil {
+		_, err = tx.Exec(`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL`, current.FamilyID)
+		if err != nil {
+			return nil, err
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+
+	_, err = tx.Exec(`UPDATE sessions SET rotated_at = CURRENT_TIMESTAMP WHERE id = $1`, current.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := models.Session{
+		FamilyID:  current.FamilyID,
+		UserID:    current.UserID,
+		TokenHash: newTokenHash,
+		ExpiresAt: expiresAt,
+	}
+	query = `INSERT INTO sessions (family_id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err = tx.QueryRow(query, next.FamilyID, next.UserID, next.TokenHash, next.ExpiresAt).Scan(&next.ID, &next.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// IsActive reports whether a session family still has an unrevoked, unexpired
+// refresh token.
+func (r *SessionRepository) IsActive(familyID string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM sessions WHERE family_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP)`
+
+	var active bool
+	if err := r.DB.QueryRow(query, familyID).Scan(&active); err != nil {
+		return false, err
+	}
+
+	return active, nil
+}
+
+// RevokeFamily revokes every refresh token issued for a session.
+func (r *SessionRepository) RevokeFamily(familyID string) error {
+	_, err := r.DB.Exec(`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL`, familyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every session of a user.
+func (r *SessionRepository) RevokeAllForUser(userID uint64) error {
+	_, err := r.DB.Exec(`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeOthers revokes every session of a user except the given one.
+func (r *SessionRepository) RevokeOthers(userID uint64, familyID string) error {
+	_, err := r.DB.Exec(`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL`, userID, familyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"database/sql"
+	"math"
+	"project01/src/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TagRepositoryInterface interface {
+	FindPosts(tag string, currentUserID uint64, page models.PageRequest) (models.Page[models.Post], error)
+	Follow(userID uint64, tag string) error
+	Unfollow(userID uint64, tag string) error
+	FollowedTags(userID uint64) ([]string, error)
+	Trends(window, halfLife time.Duration, limit int) ([]models.Trend, error)
+}
+
+func NewTagRepository(db *sql.DB) TagRepositoryInterface {
+	return &TagRepository{DB: db}
+}
+
+type TagRepository struct {
+	DB *sql.DB
+}
+
+// FindPosts retrieves a page of the top-level posts tagged with tag that the
+// current user can see, most recent first.
+func (r *TagRepository) FindPosts(tag string, currentUserID uint64, page models.PageRequest) (models.Page[models.Post], error) {
+	var posts []models.Post
+
+	query := `SELECT ` + postColumns("$2") + `
+		FROM post_tags
+		JOIN tags ON tags.id = post_tags.tag_id
+		JOIN posts ON posts.id = post_tags.post_id
+		LEFT JOIN users ON users.id = posts.author_id
+		WHERE tags.name = $1 AND posts.parent_id IS NULL AND posts.deleted_at IS NULL AND ` + visibleTo("$2") + `
+			AND ($3::timestamp IS NULL OR (post_tags.created_at, post_tags.post_id) < ($3::timestamp, $4))
+		ORDER BY post_tags.created_at DESC, post_tags.post_id DESC
+		LIMIT $5`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, tag, currentUserID, after, afterID, page.Limit+1)
+	if err != nil {
+		return models.Page[models.Post]{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post models.Post
+		if err := scanPost(rows, &post); err != nil {
+			return models.Page[models.Post]{}, err
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.Post]{}, err
+	}
+
+	postRepo := PostRepository{DB: r.DB}
+	if err := postRepo.loadRelated(posts, currentUserID); err != nil {
+		return models.Page[models.Post]{}, err
+	}
+
+	return models.NewPage(posts, page, func(i int) models.Cursor {
+		return models.TimeCursor(posts[i].CreatedAt, posts[i].ID)
+	}), nil
+}
+
+// Follow makes the posts tagged with tag show up in the user's home
+// timeline. Following a tag nobody used yet is allowed.
+func (r *TagRepository) Follow(userID uint64, tag string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tagIDs, err := upsertTags(tx, []string{tag})
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO tag_follows (user_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	if _, err = tx.Exec(query, userID, tagIDs[0]); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TagRepository) Unfollow(userID uint64, tag string) error {
+	query := `DELETE FROM tag_follows USING tags
+		WHERE tag_follows.tag_id = tags.id AND tag_follows.user_id = $1 AND tags.name = $2`
+	_, err := r.DB.Exec(query, userID, tag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FollowedTags returns the tags the user follows, by name.
+func (r *TagRepository) FollowedTags(userID uint64) ([]string, error) {
+	query := `SELECT tags.name FROM tag_follows
+		JOIN tags ON tags.id = tag_follows.tag_id
+		WHERE tag_follows.user_id = $1
+		ORDER BY tags.name`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// Trends returns the limit tags with the highest score over the last window.
+// Each use is weighted by 2^(-age/halfLife), so recent uses count the most,
+// and only the latest use of a tag by each account counts, so one account
+// posting a tag over and over cannot make it trend. Only public posts count,
+// since trends are shown to everyone.
+func (r *TagRepository) Trends(window, halfLife time.Duration, limit int) ([]models.Trend, error) {
+	query := `SELECT tags.name, SUM(latest.weight) AS score, SUM(latest.uses) AS uses, COUNT(*) AS accounts
+		FROM (
+			SELECT post_tags.tag_id,
+				EXP(-$3::float8 * EXTRACT(EPOCH FROM CURRENT_TIMESTAMP::timestamp - MAX(post_tags.created_at))::float8) AS weight,
+				COUNT(*) AS uses
+			FROM post_tags
+			JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL
+				AND posts.visibility = '` + models.VisibilityPublic + `'
+			WHERE post_tags.created_at > CURRENT_TIMESTAMP::timestamp - $1::float8 * INTERVAL '1 second'
+			GROUP BY post_tags.tag_id, posts.author_id
+		) AS latest
+		JOIN tags ON tags.id = latest.tag_id
+		GROUP BY tags.name
+		ORDER BY score DESC, tags.name
+		LIMIT $2`
+	decay := math.Ln2 / halfLife.Seconds()
+	rows, err := r.DB.Query(query, window.Seconds(), limit, decay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trends := []models.Trend{}
+	for rows.Next() {
+		var trend models.Trend
+		if err := rows.Scan(&trend.Tag, &trend.Score, &trend.Uses, &trend.Accounts); err != nil {
+			return nil, err
+		}
+		trends = append(trends, trend)
+	}
+
+	return trends, rows.Err()
+}
+
+// upsertTags creates the tags that do not exist yet and returns the IDs of
+// all of them, in order.
+func upsertTags(tx *sql.Tx, tags []string) ([]int64, error) {
+	query := `INSERT INTO tags (name) SELECT UNNEST($1::varchar[]) ON CONFLICT (name) DO NOTHING`
+	if _, err := tx.Exec(query, pq.Array(tags)); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT id FROM tags JOIN UNNEST($1::varchar[]) WITH ORDINALITY AS wanted(name, position) USING (name)
+		ORDER BY wanted.position`
+	rows, err := tx.Query(query, pq.Array(tags))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// syncTags makes the tags of a post those found in its content.
+func syncTags(tx *sql.Tx, postID uint64, content string) error {
+	tags := models.ExtractHashtags(content)
+
+	tagIDs := []int64{}
+	if len(tags) > 0 {
+		var err error
+		if tagIDs, err = upsertTags(tx, tags); err != nil {
+			return err
+		}
+	}
+
+	query := `DELETE FROM post_tags WHERE post_id = $1 AND NOT (tag_id = ANY($2::int[]))`
+	if _, err := tx.Exec(query, postID, pq.Array(tagIDs)); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO post_tags (post_id, tag_id, created_at)
+		SELECT posts.id, UNNEST($2::int[]), posts.created_at FROM posts WHERE posts.id = $1
+		ON CONFLICT DO NOTHING`
+	if _, err := tx.Exec(query, postID, pq.Array(tagIDs)); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"database/sql"
+)
+
+// TimelineRepositoryInterface maintains the materialized home timelines read
+// by PostRepository.PostsFollowedUsers. Authors with more followers than the
+// fan-out limit are marked fan_out_on_read instead, and their posts and
+// reposts are merged into timelines when they are read. Rows added for a
+// repost have the reposter as author_id.
+type TimelineRepositoryInterface interface {
+	FanOut(postID, authorID uint64, fanOutLimit int) error
+	FanOutRepost(repostID, reposterID uint64, fanOutLimit int) error
+	Backfill(userID, authorID uint64, limit int) error
+	RemoveAuthor(userID, authorID uint64) error
+	Rebuild(userID uint64, limit int) error
+}
+
+func NewTimelineRepository(db *sql.DB) TimelineRepositoryInterface {
+	return &TimelineRepository{DB: db}
+}
+
+type TimelineRepository struct {
+	DB *sql.DB
+}
+
+// FanOut adds a top-level post to the timelines of its author's followers.
+// Once an author has more than fanOutLimit followers they are switched to
+// fan-out on read for good, so their earlier posts never go missing.
+func (r *TimelineRepository) FanOut(postID, authorID uint64, fanOutLimit int) error {
+	query := `UPDATE users SET fan_out_on_read = TRUE
+		WHERE id = $1 AND NOT fan_out_on_read
+			AND (SELECT COUNT(*) FROM (SELECT 1 FROM followers WHERE user_id = $1 LIMIT $2 + 1) AS capped) > $2`
+	if _, err := r.DB.Exec(query, authorID, fanOutLimit); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO timelines (user_id, post_id, author_id, created_at)
+		SELECT followers.follower_id, posts.id, posts.author_id, posts.created_at
+		FROM posts
+		JOIN users ON users.id = posts.author_id
+		JOIN followers ON followers.user_id = posts.author_id
+		WHERE posts.id = $1 AND posts.parent_id IS NULL AND NOT users.fan_out_on_read AND posts.deleted_at IS NULL
+		ON CONFLICT DO NOTHING`
+	_, err := r.DB.Exec(query, postID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FanOutRepost adds a reposted post to the timelines of the reposter's
+// followers, except for the post's own author. A post already in a timeline
+// keeps its place there.
+func (r *TimelineRepository) FanOutRepost(repostID, reposterID uint64, fanOutLimit int) error {
+	query := `UPDATE users SET fan_out_on_read = TRUE
+		WHERE id = $1 AND NOT fan_out_on_read
+			AND (SELECT COUNT(*) FROM (SELECT 1 FROM followers WHERE user_id = $1 LIMIT $2 + 1) AS capped) > $2`
+	if _, err := r.DB.Exec(query, reposterID, fanOutLimit); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO timelines (user_id, post_id, author_id, created_at, repost_id)
+		SELECT followers.follower_id, reposts.post_id, reposts.user_id, reposts.created_at, reposts.id
+		FROM reposts
+		JOIN posts ON posts.id = reposts.post_id
+		JOIN users ON users.id = reposts.user_id
+		JOIN followers ON followers.user_id = reposts.user_id
+		WHERE reposts.id = $1 AND followers.follower_id <> posts.author_id AND NOT users.fan_out_on_read
+			AND posts.deleted_at IS NULL
+		ON CONFLICT DO NOTHING`
+	_, err := r.DB.Exec(query, repostID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Backfill adds the latest posts and reposts of an author a user just
+// followed to the user's timeline.
+func (r *TimelineRepository) Backfill(userID, authorID uint64, limit int) error {
+	query := `INSERT INTO timelines (user_id, post_id, author_id, created_at)
+		SELECT $1, posts.id, posts.author_id, posts.created_at
+		FROM posts
+		JOIN users ON users.id = posts.author_id
+		WHERE posts.author_id = $2 AND posts.parent_id IS NULL AND NOT users.fan_out_on_read AND posts.deleted_at IS NULL
+			AND EXISTS(SELECT 1 FROM followers WHERE follower_id = $1 AND user_id = $2)
+		ORDER BY posts.created_at DESC, posts.id DESC
+		LIMIT $3
+		ON CONFLICT DO NOTHING`
+	_, err := r.DB.Exec(query, userID, authorID, limit)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO timelines (user_id, post_id, author_id, created_at, repost_id)
+		SELECT $1, reposts.post_id, reposts.user_id, reposts.created_at, reposts.id
+		FROM reposts
+		JOIN posts ON posts.id = reposts.post_id
+		JOIN users ON users.id = reposts.user_id
+		WHERE reposts.user_id = $2 AND posts.author_id <> $1 AND NOT users.fan_out_on_read AND posts.deleted_at IS NULL
+			AND EXISTS(SELECT 1 FROM followers WHERE follower_id = $1 AND user_id = $2)
+		ORDER BY reposts.created_at DESC, reposts.id DESC
+		LIMIT $3
+		ON CONFLICT DO NOTHING`
+	_, err = r.DB.Exec(query, userID, authorID, limit)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveAuthor removes the posts of an author a user unfollowed from the
+// user's timeline.
+func (r *TimelineRepository) RemoveAuthor(userID, authorID uint64) error {
+	query := `DELETE FROM timelines
+		WHERE user_id = $1 AND author_id = $2
+			AND NOT EXISTS(SELECT 1 FROM followers WHERE follower_id = $1 AND user_id = $2)`
+	_, err := r.DB.Exec(query, userID, authorID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Rebuild replaces a user's timeline with the latest limit posts and reposts
+// of the authors they follow.
+func (r *TimelineRepository) Rebuild(userID uint64, limit int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM timelines WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	// Entries are the user's followees' posts and reposts; a post that shows
+	// up several times keeps its latest entry.
+	query := `INSERT INTO timelines (user_id, post_id, author_id, created_at, repost_id)
+		SELECT $1, post_id, author_id, created_at, repost_id FROM (
+			SELECT DISTINCT ON (post_id) post_id, author_id, created_at, repost_id FROM (
+				SELECT posts.id AS post_id, posts.author_id, posts.created_at, NULL::INT AS repost_id
+				FROM posts
+				JOIN users ON users.id = posts.author_id
+				JOIN followers ON followers.user_id = posts.author_id AND followers.follower_id = $1
+				WHERE posts.parent_id IS NULL AND NOT users.fan_out_on_read AND posts.deleted_at IS NULL
+				UNION ALL
+				SELECT reposts.post_id, reposts.user_id, reposts.created_at, reposts.id
+				FROM reposts
+				JOIN posts ON posts.id = reposts.post_id
+				JOIN users ON users.id = reposts.user_id
+				JOIN followers ON followers.user_id = reposts.user_id AND followers.follower_id = $1
+				WHERE posts.author_id <> $1 AND NOT users.fan_out_on_read AND posts.deleted_at IS NULL
+			) AS entries
+			ORDER BY post_id, created_at DESC
+		) AS latest
+		ORDER BY created_at DESC, post_id DESC
+		LIMIT $2`
+	if _, err = tx.Exec(query, userID, limit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
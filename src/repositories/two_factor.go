@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"database/sql"
+	"project01/src/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TwoFactorRepositoryInterface interface {
+	Find(userID uint64) (*models.TwoFactor, error)
+	SetPendingSecret(userID uint64, secret string) error
+	Enable(userID uint64, counter int64, recoveryCodeHashes []string) error
+	Disable(userID uint64) error
+	UseCounter(userID uint64, counter int64) (bool, error)
+	UseRecoveryCode(userID uint64, codeHash string) (bool, error)
+	UseChallenge(tokenID string, expiresAt time.Time) (bool, error)
+}
+
+func NewTwoFactorRepository(db *sql.DB) TwoFactorRepositoryInterface {
+	return &TwoFactorRepository{DB: db}
+}
+
+type TwoFactorRepository struct {
+	DB *sql.DB
+}
+
+// Find returns the TOTP enrollment of a user, or ErrNotFound if there is none.
+func (r *TwoFactorRepository) Find(userID uint64) (*models.TwoFactor, error) {
+	query := `SELECT id, totp_secret, totp_enabled_at, totp_last_counter FROM users WHERE id = $1 AND totp_secret IS NOT NULL`
+
+	var twoFactor models.TwoFactor
+	err := r.DB.QueryRow(query, userID).Scan(
+		&twoFactor.UserID,
+		&twoFactor.Secret,
+		&twoFactor.EnabledAt,
+		&twoFactor.LastCounter,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+// SetPendingSecret starts a new enrollment. It never replaces a secret that
+// is already enabled.
+func (r *TwoFactorRepository) SetPendingSecret(userID uint64, secret string) error {
+	query := `UPDATE users SET totp_secret = $1, totp_last_counter = 0 WHERE id = $2 AND totp_enabled_at IS NULL`
+	result, err := r.DB.Exec(query, secret, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Enable confirms the pending enrollment and replaces the user's recovery
+// codes.
+func (r *TwoFactorRepository) Enable(userID uint64, counter int64, recoveryCodeHashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_counter = $1
+		WHERE id = $2 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`
+	result, err := tx.Exec(query, counter, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) SELECT $1, UNNEST($2::TEXT[])`, userID, pq.Array(recoveryCodeHashes))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Disable removes the TOTP secret and every recovery code.
+func (r *TwoFactorRepository) Disable(userID uint64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0 WHERE id = $1`
+	if _, err = tx.Exec(query, userID); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseCounter records the time step of an accepted code. It returns false if
+// that step, or a later one, was already used.
+func (r *TwoFactorRepository) UseCounter(userID uint64, counter int64) (bool, error) {
+	query := `UPDATE users SET totp_last_counter = $1 WHERE id = $2 AND totp_last_counter < $1`
+	result, err := r.DB.Exec(query, counter, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// UseRecoveryCode burns a recovery code. It returns false if the code does not
+// exist or was already used.
+func (r *TwoFactorRepository) UseRecoveryCode(userID uint64, codeHash string) (bool, error) {
+	query := `UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := r.DB.Exec(query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// UseChallenge records that the challenge token tokenID completed a login. It
+// returns false if it already did. Records of expired tokens are dropped on
+// the way, as those tokens are rejected anyway.
+func (r *TwoFactorRepository) UseChallenge(tokenID string, expiresAt time.Time) (bool, error) {
+	if _, err := r.DB.Exec(`DELETE FROM used_challenge_tokens WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return false, err
+	}
+
+	query := `INSERT INTO used_challenge_tokens (token_id, expires_at) VALUES ($1, $2) ON CONFLICT (token_id) DO NOTHING`
+	result, err := r.DB.Exec(query, tokenID, expiresAt)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
@@ -3,22 +3,45 @@ package repositories
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"project01/src/models"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type UserRepositoryInterface interface {
 	Create(user *models.User) (*models.User, error)
 	FindAll() ([]models.User, error)
 	FindByID(id uint64) (*models.User, error)
+	FindByUsername(username string) (*models.User, error)
+	FindRenamed(username string) (string, error)
+	Relationship(viewerID, userID uint64) (models.Relationship, error)
 	Update(user *models.User) error
+	UpdateProfile(id uint64, update *models.UserUpdate, cooldown, redirectTTL time.Duration) error
+	UpdateImage(id uint64, kind, url, key string) (string, error)
+	FindImageKeys(keys []string) ([]string, error)
 	Delete(id uint64) error
-	FindByFilters(term string) ([]models.User, error)
+	FindByFilters(term string, page models.PageRequest) (models.Page[models.User], error)
 	FindByEmail(email string) (*models.User, error)
+	FindPassword(id uint64) (string, error)
+	UpdatePassword(id uint64, hashedPassword string) error
+	IsEmailVerified(id uint64) (bool, error)
+	MarkEmailVerified(id uint64, email string) error
+	MarkVerificationSent(id uint64) error
+	FindAccess(id uint64) (*models.User, error)
+	UpdateRole(id uint64, role string) error
+	Suspend(id uint64) error
+	Unsuspend(id uint64) error
 	Follow(followerID, userID uint64) (bool, error)
 	Unfollow(followerID, userID uint64) error
-	Followers(userID uint64) ([]models.User, error)
-	Following(userID uint64) ([]models.User, error)
+	Followers(userID uint64, page models.PageRequest) (models.Page[models.User], error)
+	Following(userID uint64, page models.PageRequest) (models.Page[models.User], error)
+	Block(blockerID, userID uint64) error
+	Unblock(blockerID, userID uint64) error
+	IsBlocking(blockerID, userID uint64) (bool, error)
+	Autocomplete(prefix string, viewerID uint64, limit int) ([]models.User, error)
 }
 
 func NewUserRepository(db *sql.DB) UserRepositoryInterface {
@@ -31,15 +54,37 @@ type UserRepository struct {
 
 var ErrNotFound = errors.New("not found")
 
+var (
+	ErrUsernameTaken    = errors.New("username is already taken")
+	ErrEmailTaken       = errors.New("email is already taken")
+	ErrUsernameCooldown = errors.New("username was changed recently, try again later")
+)
+
+// usernameTakenQuery checks whether a username is used by another user than
+// $2, ignoring case, or still redirects to one after a rename.
+const usernameTakenQuery = `SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(username) = LOWER($1) AND id <> $2)
+	OR EXISTS(SELECT 1 FROM username_history
+		WHERE LOWER(username) = LOWER($1) AND user_id <> $2 AND expires_at > CURRENT_TIMESTAMP)`
+
 func (r *UserRepository) Create(user *models.User) (*models.User, error) {
-	query := `INSERT INTO users (name, email, password, birthdate) VALUES ($1, $2, $3, $4) RETURNING id`
+	var taken bool
+	if err := r.DB.QueryRow(usernameTakenQuery, user.Username, 0).Scan(&taken); err != nil {
+		return nil, err
+	}
+
+	if taken {
+		return nil, ErrUsernameTaken
+	}
+
+	query := `INSERT INTO users (name, email, username, avatar_url, bio, password, birthdate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
 
 	var id uint64
 
-	err := r.DB.QueryRow(query, user.Name, user.Email, user.Password, user.Birthdate).Scan(&id)
+	err := r.DB.QueryRow(query, user.Name, user.Email, user.Username, user.AvatarURL, user.Bio, user.Password, user.Birthdate).Scan(&id)
 
 	if err != nil {
-		return nil, err
+		return nil, uniqueViolation(err)
 	}
 
 	return r.FindByID(id)
@@ -57,6 +102,7 @@ func (r *UserRepository) FindAll() ([]models.User, error) {
 
 	for rows.Next() {
 		var user models.User
+		var birthdate sql.NullString
 		if err := rows.Scan(
 			&user.ID,
 			&user.Name,
@@ -64,11 +110,12 @@ func (r *UserRepository) FindAll() ([]models.User, error) {
 			&user.Username,
 			&user.AvatarURL,
 			&user.Bio,
-			&user.Birthdate,
+			&birthdate,
 			&user.CreatedAt,
 		); err != nil {
 			return nil, err
 		}
+		user.Birthdate = birthdate.String
 
 		users = append(users, user)
 	}
@@ -77,19 +124,31 @@ func (r *UserRepository) FindAll() ([]models.User, error) {
 }
 
 func (r *UserRepository) FindByID(id uint64) (*models.User, error) {
-	query := `SELECT id, name, email, username, avatar_url, bio, birthdate, created_at FROM users WHERE id = $1`
+	query := `SELECT id, name, email, username, avatar_url, banner_url, bio, birthdate, created_at, email_verified_at, verification_sent_at,
+		role, suspended_at, follower_count, following_count, post_count
+		FROM users WHERE id = $1`
 	rows := r.DB.QueryRow(query, id)
 
 	var user models.User
+	// Accounts created through an external identity provider have no birthdate.
+	var birthdate sql.NullString
 	if err := rows.Scan(
 		&user.ID,
 		&user.Name,
 		&user.Email,
 		&user.Username,
 		&user.AvatarURL,
+		&user.BannerURL,
 		&user.Bio,
-		&user.Birthdate,
+		&birthdate,
 		&user.CreatedAt,
+		&user.EmailVerifiedAt,
+		&user.VerificationSentAt,
+		&user.Role,
+		&user.SuspendedAt,
+		&user.FollowerCount,
+		&user.FollowingCount,
+		&user.PostCount,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -97,16 +156,80 @@ func (r *UserRepository) FindByID(id uint64) (*models.User, error) {
 
 		return nil, err
 	}
+	user.Birthdate = birthdate.String
 
 	return &user, nil
 }
 
+// FindByUsername finds a user by username, ignoring case.
+func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
+	query := `SELECT id FROM users WHERE LOWER(username) = LOWER($1)`
+
+	var id uint64
+	if err := r.DB.QueryRow(query, username).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return r.FindByID(id)
+}
+
+// FindRenamed returns the current username of the user who went by username
+// before a rename, as long as the old name still redirects.
+func (r *UserRepository) FindRenamed(username string) (string, error) {
+	query := `SELECT users.username FROM username_history
+		JOIN users ON users.id = username_history.user_id
+		WHERE LOWER(username_history.username) = LOWER($1) AND username_history.expires_at > CURRENT_TIMESTAMP`
+
+	var current string
+	if err := r.DB.QueryRow(query, username).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+
+		return "", err
+	}
+
+	return current, nil
+}
+
+// Relationship returns how viewerID is connected to userID.
+func (r *UserRepository) Relationship(viewerID, userID uint64) (models.Relationship, error) {
+	query := `SELECT
+		EXISTS(SELECT 1 FROM followers WHERE follower_id = $1 AND user_id = $2),
+		EXISTS(SELECT 1 FROM followers WHERE follower_id = $2 AND user_id = $1),
+		(SELECT COUNT(*) FROM followers AS theirs
+			JOIN followers AS mine ON mine.user_id = theirs.follower_id AND mine.follower_id = $1
+			WHERE theirs.user_id = $2),
+		EXISTS(SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2)`
+
+	var relationship models.Relationship
+	err := r.DB.QueryRow(query, viewerID, userID).Scan(
+		&relationship.Following,
+		&relationship.FollowedBy,
+		&relationship.MutualFollowersCount,
+		&relationship.Blocking,
+	)
+	if err != nil {
+		return models.Relationship{}, err
+	}
+
+	return relationship, nil
+}
+
+// Update updates the user's details. Changing the email address marks it as
+// unverified again.
 func (r *UserRepository) Update(user *models.User) error {
-	query := `UPDATE users SET name = $1, email = $2, birthdate = $3 WHERE id = $4`
+	query := `UPDATE users SET name = $1, email = $2, birthdate = $3,
+		email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END
+		WHERE id = $4`
 	result, err := r.DB.Exec(query, user.Name, user.Email, user.Birthdate, user.ID)
 
 	if err != nil {
-		return err
+		return uniqueViolation(err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -121,9 +244,214 @@ func (r *UserRepository) Update(user *models.User) error {
 	return nil
 }
 
+// UpdateProfile writes the fields present in update. A username can only be
+// changed once per cooldown; the old one keeps redirecting to the user for
+// redirectTTL and cannot be claimed by anyone else until then. Changing only
+// the case of the username is always allowed. Changing the email address
+// marks it as unverified again.
+func (r *UserRepository) UpdateProfile(id uint64, update *models.UserUpdate, cooldown, redirectTTL time.Duration) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var currentUsername string
+	var usernameChangedAt *time.Time
+	query := `SELECT username, username_changed_at FROM users WHERE id = $1 FOR UPDATE`
+	if err = tx.QueryRow(query, id).Scan(&currentUsername, &usernameChangedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if update.Name != nil {
+		set("name", *update.Name)
+	}
+
+	if update.Username != nil && *update.Username != currentUsername {
+		if !strings.EqualFold(*update.Username, currentUsername) {
+			if usernameChangedAt != nil && time.Since(*usernameChangedAt) < cooldown {
+				return ErrUsernameCooldown
+			}
+
+			var taken bool
+			if err = tx.QueryRow(usernameTakenQuery, *update.Username, id).Scan(&taken); err != nil {
+				return err
+			}
+
+			if taken {
+				return ErrUsernameTaken
+			}
+
+			// Taking back one of your own old usernames ends its redirect.
+			_, err = tx.Exec(`DELETE FROM username_history WHERE user_id = $1 AND LOWER(username) = LOWER($2)`, id, *update.Username)
+			if err != nil {
+				return err
+			}
+
+			query = `INSERT INTO username_history (user_id, username, expires_at) VALUES ($1, $2, $3)
+				ON CONFLICT ((LOWER(username))) DO UPDATE
+				SET user_id = EXCLUDED.user_id, username = EXCLUDED.username, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP`
+			if _, err = tx.Exec(query, id, currentUsername, time.Now().Add(redirectTTL)); err != nil {
+				return err
+			}
+
+			sets = append(sets, "username_changed_at = CURRENT_TIMESTAMP")
+		}
+
+		set("username", *update.Username)
+	}
+
+	if update.AvatarURL != nil {
+		set("avatar_url", *update.AvatarURL)
+		// An uploaded avatar is no longer used and is left to the media
+		// garbage collector.
+		sets = append(sets, "avatar_key = ''")
+	}
+
+	if update.Bio != nil {
+		set("bio", *update.Bio)
+	}
+
+	if update.Birthdate != nil {
+		var birthdate interface{}
+		if *update.Birthdate != "" {
+			birthdate = *update.Birthdate
+		}
+		set("birthdate", birthdate)
+	}
+
+	if update.Email != nil {
+		set("email", *update.Email)
+		sets = append(sets, fmt.Sprintf("email_verified_at = CASE WHEN email = $%d THEN email_verified_at ELSE NULL END", len(args)))
+	}
+
+	if len(sets) > 0 {
+		args = append(args, id)
+		query = fmt.Sprintf("UPDATE users SET %s WHERE id = $%d", strings.Join(sets, ", "), len(args))
+		if _, err = tx.Exec(query, args...); err != nil {
+			return uniqueViolation(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateImage points the "avatar" or "banner" of a user at an uploaded file,
+// or clears it when url and key are empty, and returns the storage key of the
+// file it replaced, if there was one.
+func (r *UserRepository) UpdateImage(id uint64, kind, url, key string) (string, error) {
+	var urlColumn, keyColumn string
+	switch kind {
+	case "avatar":
+		urlColumn, keyColumn = "avatar_url", "avatar_key"
+	case "banner":
+		urlColumn, keyColumn = "banner_url", "banner_key"
+	default:
+		return "", fmt.Errorf("unknown image kind %q", kind)
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var oldKey string
+	query := fmt.Sprintf(`SELECT %s FROM users WHERE id = $1 FOR UPDATE`, keyColumn)
+	if err = tx.QueryRow(query, id).Scan(&oldKey); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+
+		return "", err
+	}
+
+	query = fmt.Sprintf(`UPDATE users SET %s = $1, %s = $2 WHERE id = $3`, urlColumn, keyColumn)
+	if _, err = tx.Exec(query, url, key, id); err != nil {
+		return "", err
+	}
+
+	return oldKey, tx.Commit()
+}
+
+// FindImageKeys returns which of keys are still the avatar or banner of a
+// user.
+func (r *UserRepository) FindImageKeys(keys []string) ([]string, error) {
+	query := `SELECT avatar_key FROM users WHERE avatar_key = ANY($1)
+		UNION SELECT banner_key FROM users WHERE banner_key = ANY($1)`
+	rows, err := r.DB.Query(query, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var used []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		used = append(used, key)
+	}
+
+	return used, rows.Err()
+}
+
+// Delete deletes a user and takes their follows, likes, reposts and replies
+// out of the counters of the users and posts they pointed at. Their posts
+// are deleted the way PostRepository.Delete does it, staying as tombstones
+// without an author so that the replies below them survive.
 func (r *UserRepository) Delete(id uint64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	counterQueries := []string{
+		`UPDATE users SET follower_count = GREATEST(follower_count - 1, 0)
+			WHERE id IN (SELECT user_id FROM followers WHERE follower_id = $1)`,
+		`UPDATE users SET following_count = GREATEST(following_count - 1, 0)
+			WHERE id IN (SELECT follower_id FROM followers WHERE user_id = $1)`,
+		`UPDATE posts SET like_count = GREATEST(like_count - 1, 0)
+			WHERE id IN (SELECT post_id FROM likes WHERE user_id = $1)`,
+		`UPDATE posts SET repost_count = GREATEST(repost_count - 1, 0)
+			WHERE id IN (SELECT post_id FROM reposts WHERE user_id = $1)`,
+		`UPDATE posts SET reply_count = GREATEST(reply_count - replies.total, 0)
+			FROM (SELECT parent_id, COUNT(*) AS total FROM posts
+				WHERE author_id = $1 AND parent_id IS NOT NULL AND deleted_at IS NULL GROUP BY parent_id) AS replies
+			WHERE posts.id = replies.parent_id`,
+	}
+	for _, query := range counterQueries {
+		if _, err = tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+
+	postQueries := []string{
+		`UPDATE posts SET deleted_at = CURRENT_TIMESTAMP, deleted_by = 'author'
+			WHERE author_id = $1 AND deleted_at IS NULL`,
+		`DELETE FROM timelines WHERE post_id IN (SELECT id FROM posts WHERE author_id = $1)`,
+	}
+	for _, query := range postQueries {
+		if _, err = tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+
 	query := `DELETE FROM users WHERE id = $1`
-	result, err := r.DB.Exec(query, id)
+	result, err := tx.Exec(query, id)
 
 	if err != nil {
 		return err
@@ -138,19 +466,25 @@ func (r *UserRepository) Delete(id uint64) error {
 		return ErrNotFound
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (r *UserRepository) FindByFilters(term string) ([]models.User, error) {
+// FindByFilters returns a page of the users whose name or username contains
+// term, ordered by name. Emails are not searched, so that the search cannot
+// tell whether an address has an account.
+func (r *UserRepository) FindByFilters(term string, page models.PageRequest) (models.Page[models.User], error) {
 	term = strings.ToLower(term)
 
 	query := `SELECT id, name, email, username, avatar_url, bio, birthdate, created_at
 				FROM users
-        WHERE LOWER(name) LIKE $1 OR LOWER(email) LIKE $1 OR LOWER(username) LIKE $1
-        ORDER BY name ASC`
-	rows, err := r.DB.Query(query, "%"+term+"%")
+        WHERE (LOWER(name) LIKE $1 OR LOWER(username) LIKE $1)
+          AND ($2::text IS NULL OR (name, id) > ($2::text, $3))
+        ORDER BY name ASC, id ASC
+        LIMIT $4`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, "%"+term+"%", after, afterID, page.Limit+1)
 	if err != nil {
-		return nil, err
+		return models.Page[models.User]{}, err
 	}
 	defer rows.Close()
 
@@ -158,6 +492,7 @@ func (r *UserRepository) FindByFilters(term string) ([]models.User, error) {
 
 	for rows.Next() {
 		var user models.User
+		var birthdate sql.NullString
 		if err := rows.Scan(
 			&user.ID,
 			&user.Name,
@@ -165,36 +500,134 @@ func (r *UserRepository) FindByFilters(term string) ([]models.User, error) {
 			&user.Username,
 			&user.AvatarURL,
 			&user.Bio,
-			&user.Birthdate,
+			&birthdate,
 			&user.CreatedAt,
 		); err != nil {
-			return nil, err
+			return models.Page[models.User]{}, err
 		}
+		user.Birthdate = birthdate.String
 
 		users = append(users, user)
 	}
 
-	return users, nil
+	if err := rows.Err(); err != nil {
+		return models.Page[models.User]{}, err
+	}
+
+	return models.NewPage(users, page, func(i int) models.Cursor {
+		return models.Cursor{Value: users[i].Name, ID: users[i].ID}
+	}), nil
 }
 
 func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
-	query := `SELECT id, password FROM users WHERE email = $1`
+	query := `SELECT id, password, suspended_at FROM users WHERE email = $1`
 	rows := r.DB.QueryRow(query, email)
 
 	var user models.User
-	if err := rows.Scan(&user.ID, &user.Password); err != nil {
+	if err := rows.Scan(&user.ID, &user.Password, &user.SuspendedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
 		return nil, err
 	}
 
 	return &user, nil
 }
 
+func (r *UserRepository) FindPassword(id uint64) (string, error) {
+	query := `SELECT password FROM users WHERE id = $1`
+
+	var password string
+	if err := r.DB.QueryRow(query, id).Scan(&password); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+
+		return "", err
+	}
+
+	return password, nil
+}
+
+func (r *UserRepository) UpdatePassword(id uint64, hashedPassword string) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+	result, err := r.DB.Exec(query, hashedPassword, id)
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) IsEmailVerified(id uint64) (bool, error) {
+	query := `SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1`
+
+	var verified bool
+	if err := r.DB.QueryRow(query, id).Scan(&verified); err != nil {
+		if err == sql.ErrNoRows {
+			return false, ErrNotFound
+		}
+
+		return false, err
+	}
+
+	return verified, nil
+}
+
+// MarkEmailVerified verifies the user's email, provided it is still the
+// address the verification link was sent to.
+func (r *UserRepository) MarkEmailVerified(id uint64, email string) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP) WHERE id = $1 AND email = $2`
+	result, err := r.DB.Exec(query, id, email)
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) MarkVerificationSent(id uint64) error {
+	_, err := r.DB.Exec(`UPDATE users SET verification_sent_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (r *UserRepository) Follow(followerID, userID uint64) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO followers (follower_id, user_id) VALUES ($1, $2) ON CONFLICT (follower_id, user_id) DO NOTHING RETURNING id`
 
 	var id uint64
 
-	err := r.DB.QueryRow(query, followerID, userID).Scan(&id)
+	err = tx.QueryRow(query, followerID, userID).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -203,66 +636,322 @@ func (r *UserRepository) Follow(followerID, userID uint64) (bool, error) {
 		return false, err
 	}
 
+	if err = updateFollowCounts(tx, followerID, userID, 1); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
 func (r *UserRepository) Unfollow(followerID, userID uint64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `DELETE FROM followers WHERE follower_id = $1 AND user_id = $2`
-	_, err := r.DB.Exec(query, followerID, userID)
+	result, err := tx.Exec(query, followerID, userID)
 
 	if err != nil {
 		return err
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		if err = updateFollowCounts(tx, followerID, userID, -1); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Block blocks userID for blockerID and removes the follows between them in
+// both directions.
+func (r *UserRepository) Block(blockerID, userID uint64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	if _, err = tx.Exec(query, blockerID, userID); err != nil {
+		return err
+	}
+
+	query = `DELETE FROM followers
+		WHERE (follower_id = $1 AND user_id = $2) OR (follower_id = $2 AND user_id = $1)
+		RETURNING follower_id, user_id`
+	rows, err := tx.Query(query, blockerID, userID)
+	if err != nil {
+		return err
+	}
+
+	var removed [][2]uint64
+	for rows.Next() {
+		var followerID, followedID uint64
+		if err := rows.Scan(&followerID, &followedID); err != nil {
+			rows.Close()
+			return err
+		}
+		removed = append(removed, [2]uint64{followerID, followedID})
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, follow := range removed {
+		if err = updateFollowCounts(tx, follow[0], follow[1], -1); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) Unblock(blockerID, userID uint64) error {
+	_, err := r.DB.Exec(`DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerID, userID)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (r *UserRepository) Followers(userID uint64) ([]models.User, error) {
-	query := `SELECT users.id, users.name, users.username, users.avatar_url, users.bio
+// IsBlocking reports whether blockerID blocked userID.
+func (r *UserRepository) IsBlocking(blockerID, userID uint64) (bool, error) {
+	var blocking bool
+	query := `SELECT EXISTS(SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2)`
+	if err := r.DB.QueryRow(query, blockerID, userID).Scan(&blocking); err != nil {
+		return false, err
+	}
+
+	return blocking, nil
+}
+
+// Autocomplete returns up to limit users whose username or name starts with
+// prefix, for completing mentions. Users the viewer follows come first, then
+// the most followed. Users who blocked the viewer are left out.
+func (r *UserRepository) Autocomplete(prefix string, viewerID uint64, limit int) ([]models.User, error) {
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(prefix)) + "%"
+
+	query := `SELECT users.id, users.name, users.username, users.avatar_url
+		FROM users
+		WHERE (LOWER(users.username) LIKE $1 OR LOWER(users.name) LIKE $1)
+			AND NOT EXISTS(SELECT 1 FROM blocks WHERE blocker_id = users.id AND blocked_id = $2)
+		ORDER BY EXISTS(SELECT 1 FROM followers WHERE follower_id = $2 AND user_id = users.id) DESC,
+			users.follower_count DESC, users.username
+		LIMIT $3`
+	rows, err := r.DB.Query(query, pattern, viewerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.AvatarURL); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// updateFollowCounts adds delta to the following count of followerID and the
+// follower count of userID.
+func updateFollowCounts(tx *sql.Tx, followerID, userID uint64, delta int) error {
+	_, err := tx.Exec(`UPDATE users SET following_count = GREATEST(following_count + $2, 0) WHERE id = $1`, followerID, delta)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET follower_count = GREATEST(follower_count + $2, 0) WHERE id = $1`, userID, delta)
+	return err
+}
+
+// Followers returns a page of the users following userID, most recent first.
+func (r *UserRepository) Followers(userID uint64, page models.PageRequest) (models.Page[models.User], error) {
+	query := `SELECT users.id, users.name, users.username, users.avatar_url, users.bio, followers.id, followers.created_at
 		FROM followers
 		LEFT JOIN users ON users.id = followers.follower_id
-		WHERE followers.user_id = $1 ORDER BY followers.created_at DESC`
-	rows, err := r.DB.Query(query, userID)
+		WHERE followers.user_id = $1
+			AND ($2::timestamp IS NULL OR (followers.created_at, followers.id) < ($2::timestamp, $3))
+		ORDER BY followers.created_at DESC, followers.id DESC
+		LIMIT $4`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, userID, after, afterID, page.Limit+1)
 	if err != nil {
-		return nil, err
+		return models.Page[models.User]{}, err
 	}
 	defer rows.Close()
 
 	var users []models.User
+	var cursors []models.Cursor
 
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.AvatarURL, &user.Bio); err != nil {
-			return nil, err
+		var followID uint64
+		var followedAt time.Time
+		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.AvatarURL, &user.Bio, &followID, &followedAt); err != nil {
+			return models.Page[models.User]{}, err
 		}
 
 		users = append(users, user)
+		cursors = append(cursors, models.TimeCursor(followedAt, followID))
 	}
 
-	return users, nil
+	if err := rows.Err(); err != nil {
+		return models.Page[models.User]{}, err
+	}
+
+	return models.NewPage(users, page, func(i int) models.Cursor {
+		return cursors[i]
+	}), nil
 }
 
-func (r *UserRepository) Following(userID uint64) ([]models.User, error) {
-	query := `SELECT users.id, users.name, users.username, users.avatar_url, users.bio
+// Following returns a page of the users userID follows, most recent first.
+func (r *UserRepository) Following(userID uint64, page models.PageRequest) (models.Page[models.User], error) {
+	query := `SELECT users.id, users.name, users.username, users.avatar_url, users.bio, followers.id, followers.created_at
 		FROM followers
 		LEFT JOIN users ON users.id = followers.user_id
-		WHERE followers.follower_id = $1 ORDER BY followers.created_at DESC`
-	rows, err := r.DB.Query(query, userID)
+		WHERE followers.follower_id = $1
+			AND ($2::timestamp IS NULL OR (followers.created_at, followers.id) < ($2::timestamp, $3))
+		ORDER BY followers.created_at DESC, followers.id DESC
+		LIMIT $4`
+	after, afterID := page.AfterValue()
+	rows, err := r.DB.Query(query, userID, after, afterID, page.Limit+1)
 	if err != nil {
-		return nil, err
+		return models.Page[models.User]{}, err
 	}
 	defer rows.Close()
 
 	var users []models.User
+	var cursors []models.Cursor
 
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.AvatarURL, &user.Bio); err != nil {
-			return nil, err
+		var followID uint64
+		var followedAt time.Time
+		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.AvatarURL, &user.Bio, &followID, &followedAt); err != nil {
+			return models.Page[models.User]{}, err
 		}
 
 		users = append(users, user)
+		cursors = append(cursors, models.TimeCursor(followedAt, followID))
 	}
 
-	return users, nil
+	if err := rows.Err(); err != nil {
+		return models.Page[models.User]{}, err
+	}
+
+	return models.NewPage(users, page, func(i int) models.Cursor {
+		return cursors[i]
+	}), nil
+}
+
+// FindAccess returns the role and suspension state of a user, which
+// middlewares.AuthMiddleware checks on every request.
+func (r *UserRepository) FindAccess(id uint64) (*models.User, error) {
+	query := `SELECT id, role, suspended_at FROM users WHERE id = $1`
+
+	var user models.User
+	if err := r.DB.QueryRow(query, id).Scan(&user.ID, &user.Role, &user.SuspendedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) UpdateRole(id uint64, role string) error {
+	query := `UPDATE users SET role = $1 WHERE id = $2`
+	result, err := r.DB.Exec(query, role, id)
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) Suspend(id uint64) error {
+	query := `UPDATE users SET suspended_at = COALESCE(suspended_at, CURRENT_TIMESTAMP) WHERE id = $1`
+	result, err := r.DB.Exec(query, id)
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) Unsuspend(id uint64) error {
+	query := `UPDATE users SET suspended_at = NULL WHERE id = $1`
+	result, err := r.DB.Exec(query, id)
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// uniqueViolation maps a unique constraint violation on users to the error
+// naming the field that is taken.
+func uniqueViolation(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		switch pqErr.Constraint {
+		case "users_username_key":
+			return ErrUsernameTaken
+		case "users_email_key":
+			return ErrEmailTaken
+		}
+	}
+
+	return err
 }
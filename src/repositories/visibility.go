@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"project01/src/models"
+)
+
+// visibleTo returns the condition under which the row aliased posts is
+// visible to the user whose ID is in the placeholder viewer. It is the only
+// place post visibility is decided: every query that reads posts on behalf
+// of a user filters with it, and IsVisible checks single posts with it.
+// The author of the post a reply answers always sees the reply, since
+// replies to followers-only and direct posts are narrowed to match them.
+func visibleTo(viewer string) string {
+	return `(posts.visibility = '` + models.VisibilityPublic + `'
+		OR posts.author_id = ` + viewer + `
+		OR EXISTS(SELECT 1 FROM posts AS parents WHERE parents.id = posts.parent_id AND parents.author_id = ` + viewer + `)
+		OR (posts.visibility = '` + models.VisibilityFollowers + `'
+			AND EXISTS(SELECT 1 FROM followers WHERE followers.user_id = posts.author_id AND followers.follower_id = ` + viewer + `))
+		OR (posts.visibility = '` + models.VisibilityDirect + `'
+			AND EXISTS(SELECT 1 FROM post_mentions WHERE post_mentions.post_id = posts.id AND post_mentions.user_id = ` + viewer + `)))`
+}
+
+// IsVisible reports whether a post exists, is not deleted and is visible to
+// viewerID.
+func (r *PostRepository) IsVisible(postID, viewerID uint64) (bool, error) {
+	var visible bool
+	query := `SELECT EXISTS(SELECT 1 FROM posts WHERE posts.id = $1 AND posts.deleted_at IS NULL AND ` + visibleTo("$2") + `)`
+	if err := r.DB.QueryRow(query, postID, viewerID).Scan(&visible); err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
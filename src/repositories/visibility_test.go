@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"project01/src/config"
+	"project01/src/db"
+	"project01/src/migrate"
+	"project01/src/models"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testDB connects to the database named by TEST_DB_NAME, with the other
+// DB_* variables, and migrates it. The tests that need it are skipped when
+// TEST_DB_NAME is unset. They leave their rows behind, so never point it at
+// a database you care about.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	name := os.Getenv("TEST_DB_NAME")
+	if name == "" {
+		t.Skip("TEST_DB_NAME is not set")
+	}
+
+	config.Host = os.Getenv("DB_HOST")
+	config.Username = os.Getenv("DB_USER")
+	config.Password = os.Getenv("DB_PASSWORD")
+	config.DBName = name
+	port, err := strconv.Atoi(os.Getenv("DB_PORT"))
+	if err != nil {
+		port = 5432
+	}
+	config.Port = port
+
+	conn, err := db.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if _, err = migrate.Up(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+// createTestUser creates a user with a username unique to this run.
+func createTestUser(t *testing.T, users *UserRepository, prefix string) *models.User {
+	t.Helper()
+
+	username := fmt.Sprintf("%s%d", prefix, time.Now().UnixNano())
+	user, err := users.Create(&models.User{
+		Name:      prefix,
+		Email:     username + "@example.com",
+		Username:  username,
+		Password:  "not a hash",
+		Birthdate: "2000-01-01",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func createTestPost(t *testing.T, posts *PostRepository, post models.Post) *models.Post {
+	t.Helper()
+
+	created, err := posts.Create(&post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return created
+}
+
+func assertVisible(t *testing.T, posts *PostRepository, postID uint64, viewer *models.User, want bool) {
+	t.Helper()
+
+	visible, err := posts.IsVisible(postID, viewer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if visible != want {
+		t.Errorf("post %d visible to %s = %v, want %v", postID, viewer.Name, visible, want)
+	}
+}
+
+func TestNarrowedRepliesAreVisibleToParentAuthor(t *testing.T) {
+	conn := testDB(t)
+	users, posts := &UserRepository{DB: conn}, &PostRepository{DB: conn}
+
+	alice := createTestUser(t, users, "alice")
+	bob := createTestUser(t, users, "bob")
+	carol := createTestUser(t, users, "carol")
+
+	// A direct reply that does not mention the author it answers.
+	direct := createTestPost(t, posts, models.Post{
+		Content: "hi @" + bob.Username, AuthorID: alice.ID, Visibility: models.VisibilityDirect,
+	})
+	directReply := createTestPost(t, posts, models.Post{
+		Content: "hi back", AuthorID: bob.ID, ParentID: &direct.ID, Visibility: models.VisibilityDirect,
+	})
+
+	assertVisible(t, posts, directReply.ID, alice, true)
+	assertVisible(t, posts, directReply.ID, bob, true)
+	assertVisible(t, posts, directReply.ID, carol, false)
+
+	// A followers-only reply by someone the parent's author does not follow.
+	public := createTestPost(t, posts, models.Post{
+		Content: "hello", AuthorID: alice.ID, Visibility: models.VisibilityPublic,
+	})
+	followersReply := createTestPost(t, posts, models.Post{
+		Content: "hello to you", AuthorID: bob.ID, ParentID: &public.ID, Visibility: models.VisibilityFollowers,
+	})
+
+	assertVisible(t, posts, followersReply.ID, alice, true)
+	assertVisible(t, posts, followersReply.ID, carol, false)
+}
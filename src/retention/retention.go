@@ -0,0 +1,34 @@
+package retention
+
+import (
+	"database/sql"
+	"log"
+	"project01/src/config"
+	"project01/src/repositories"
+	"time"
+)
+
+// Start purges deleted posts every config.PostPurgeInterval. Running it on
+// several instances at once is harmless, as purging is idempotent.
+func Start(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(config.PostPurgeInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := Purge(db)
+			if err != nil {
+				log.Printf("retention: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("retention: purged %d deleted posts", purged)
+			}
+		}
+	}()
+}
+
+// Purge erases the posts deleted more than config.PostRetention ago and
+// returns how many it erased.
+func Purge(db *sql.DB) (int64, error) {
+	return repositories.NewPostRepository(db).Purge(config.PostRetention)
+}
@@ -2,7 +2,11 @@ package router
 
 import (
 	"database/sql"
+	"project01/src/auth"
+	"project01/src/middlewares"
+	"project01/src/repositories"
 	"project01/src/router/routes"
+	"project01/src/storage"
 	"project01/src/websocket"
 
 	"github.com/gorilla/mux"
@@ -12,6 +16,15 @@ func New(db *sql.DB) *mux.Router {
 	r := mux.NewRouter()
 	routes.Load(r, db)
 
-	r.HandleFunc("/ws", websocket.HandleConnections)
+	sessionRepo := repositories.NewSessionRepository(db)
+	tokenRepo := repositories.NewPersonalAccessTokenRepository(db)
+	userRepo := repositories.NewUserRepository(db)
+
+	r.HandleFunc("/ws", middlewares.AuthMiddleware(sessionRepo, tokenRepo, userRepo,
+		middlewares.ScopeMiddleware(auth.ScopeNotificationsRead, websocket.HandleConnections)))
+
+	if local, ok := storage.New().(*storage.LocalStorage); ok {
+		r.PathPrefix(storage.LocalPathPrefix).Handler(local.Handler())
+	}
 	return r
 }
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/controllers"
+	"project01/src/policy"
+)
+
+func adminRoutes(db *sql.DB) []Route {
+	adminController := controllers.NewAdminController(db)
+
+	return []Route{
+		{
+			URI:          "/admin/users/{id}/role",
+			Method:       http.MethodPut,
+			Function:     adminController.UpdateRole,
+			AuthRequired: true,
+			Permission:   policy.ManageRoles,
+		},
+		{
+			URI:          "/admin/users/{id}/suspension",
+			Method:       http.MethodPost,
+			Function:     adminController.SuspendUser,
+			AuthRequired: true,
+			Permission:   policy.SuspendUser,
+		},
+		{
+			URI:          "/admin/users/{id}/suspension",
+			Method:       http.MethodDelete,
+			Function:     adminController.UnsuspendUser,
+			AuthRequired: true,
+			Permission:   policy.UnsuspendUser,
+		},
+		{
+			URI:          "/admin/audit-logs",
+			Method:       http.MethodGet,
+			Function:     adminController.FindAuditLogs,
+			AuthRequired: true,
+			Permission:   policy.ViewAuditLogs,
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/controllers"
+)
+
+func identityRoutes(db *sql.DB) []Route {
+	identityController := controllers.NewIdentityController(db)
+
+	return []Route{
+		{
+			URI:          "/auth/{provider}/login",
+			Method:       http.MethodGet,
+			Function:     identityController.ProviderLogin,
+			AuthRequired: false,
+		},
+		{
+			URI:          "/auth/{provider}/callback",
+			Method:       http.MethodGet,
+			Function:     identityController.ProviderCallback,
+			AuthRequired: false,
+		},
+		{
+			URI:          "/identities",
+			Method:       http.MethodGet,
+			Function:     identityController.FindAllIdentities,
+			AuthRequired: true,
+		},
+		{
+			URI:          "/identities/{provider}",
+			Method:       http.MethodPost,
+			Function:     identityController.LinkIdentity,
+			AuthRequired: true,
+		},
+		{
+			URI:          "/identities/{id:[0-9]+}",
+			Method:       http.MethodDelete,
+			Function:     identityController.UnlinkIdentity,
+			AuthRequired: true,
+		},
+	}
+}
@@ -1,15 +1,52 @@
 package routes
 
 import (
+	"database/sql"
 	"net/http"
 	"project01/src/controllers"
 )
 
-var loginRoutes = []Route{
-	{
-		URI:          "/login",
-		Method:       http.MethodPost,
-		Function:     controllers.Login,
-		AuthRequired: false,
-	},
+func loginRoutes(db *sql.DB) []Route {
+	loginController := controllers.NewLoginController(db)
+
+	return []Route{
+		{
+			URI:          "/login",
+			Method:       http.MethodPost,
+			Function:     loginController.Login,
+			AuthRequired: false,
+		},
+		{
+			URI:          "/login/2fa",
+			Method:       http.MethodPost,
+			Function:     loginController.LoginTwoFactor,
+			AuthRequired: false,
+		},
+		{
+			URI:          "/token/refresh",
+			Method:       http.MethodPost,
+			Function:     loginController.RefreshToken,
+			AuthRequired: false,
+		},
+		{
+			URI:          "/login-attempts",
+			Method:       http.MethodGet,
+			Function:     loginController.LoginAttempts,
+			AuthRequired: true,
+		},
+		{
+			URI:             "/logout",
+			Method:          http.MethodPost,
+			Function:        loginController.Logout,
+			AuthRequired:    true,
+			AllowUnverified: true,
+		},
+		{
+			URI:             "/logout-all",
+			Method:          http.MethodPost,
+			Function:        loginController.LogoutAll,
+			AuthRequired:    true,
+			AllowUnverified: true,
+		},
+	}
 }
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/auth"
+	"project01/src/controllers"
+)
+
+func mediaRoutes(db *sql.DB) []Route {
+	mediaController := controllers.NewMediaController(db)
+
+	return []Route{
+		{
+			URI:          "/media",
+			Method:       http.MethodPost,
+			Function:     mediaController.UploadMedia,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
+		},
+		{
+			URI:          "/media/{id:[0-9]+}",
+			Method:       http.MethodGet,
+			Function:     mediaController.FindMedia,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
+		},
+		{
+			URI:          "/media/{id:[0-9]+}",
+			Method:       http.MethodPut,
+			Function:     mediaController.UpdateMedia,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
+		},
+	}
+}
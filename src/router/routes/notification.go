@@ -3,6 +3,7 @@ package routes
 import (
 	"database/sql"
 	"net/http"
+	"project01/src/auth"
 	"project01/src/controllers"
 )
 
@@ -15,30 +16,35 @@ func notificationRoutes(db *sql.DB) []Route {
 			Method:       http.MethodGet,
 			Function:     notificationController.FindAllNotifications,
 			AuthRequired: true,
+			Scope:        auth.ScopeNotificationsRead,
 		},
 		{
 			URI:          "/notifications/{id}",
 			Method:       http.MethodGet,
 			Function:     notificationController.FindNotificationByID,
 			AuthRequired: true,
+			Scope:        auth.ScopeNotificationsRead,
 		},
 		{
 			URI:          "/notifications",
 			Method:       http.MethodDelete,
 			Function:     notificationController.DeleteNotification,
 			AuthRequired: true,
+			Scope:        auth.ScopeNotificationsWrite,
 		},
 		{
 			URI:          "/notifications/{id}/read",
 			Method:       http.MethodPut,
 			Function:     notificationController.MarkNotificationAsRead,
 			AuthRequired: true,
+			Scope:        auth.ScopeNotificationsWrite,
 		},
 		{
 			URI:          "/notifications/read-all",
 			Method:       http.MethodPut,
 			Function:     notificationController.MarkAllNotificationsAsRead,
 			AuthRequired: true,
+			Scope:        auth.ScopeNotificationsWrite,
 		},
 	}
 }
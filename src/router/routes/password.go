@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/controllers"
+)
+
+func passwordRoutes(db *sql.DB) []Route {
+	passwordController := controllers.NewPasswordController(db)
+
+	return []Route{
+		{
+			URI:             "/users/{id}/password",
+			Method:          http.MethodPut,
+			Function:        passwordController.ChangePassword,
+			AuthRequired:    true,
+			AllowUnverified: true,
+		},
+		{
+			URI:          "/password/forgot",
+			Method:       http.MethodPost,
+			Function:     passwordController.ForgotPassword,
+			AuthRequired: false,
+		},
+		{
+			URI:          "/password/reset",
+			Method:       http.MethodPost,
+			Function:     passwordController.ResetPassword,
+			AuthRequired: false,
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/controllers"
+)
+
+func personalAccessTokenRoutes(db *sql.DB) []Route {
+	tokenController := controllers.NewPersonalAccessTokenController(db)
+
+	return []Route{
+		{
+			URI:          "/tokens",
+			Method:       http.MethodPost,
+			Function:     tokenController.NewToken,
+			AuthRequired: true,
+		},
+		{
+			URI:          "/tokens",
+			Method:       http.MethodGet,
+			Function:     tokenController.FindAllTokens,
+			AuthRequired: true,
+		},
+		{
+			URI:          "/tokens/{id}",
+			Method:       http.MethodDelete,
+			Function:     tokenController.RevokeToken,
+			AuthRequired: true,
+		},
+	}
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"database/sql"
 	"net/http"
+	"project01/src/auth"
 	"project01/src/controllers"
 )
 
@@ -15,60 +16,119 @@ func postRoutes(db *sql.DB) []Route {
 			Method:       http.MethodPost,
 			Function:     postController.NewPost,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
 		},
 		{
 			URI:          "/posts",
 			Method:       http.MethodGet,
 			Function:     postController.PostsFollowedUsers,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
 		},
 		{
 			URI:          "/posts/{id}",
 			Method:       http.MethodGet,
 			Function:     postController.FindPost,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
 		},
 		{
 			URI:          "/posts/{id}",
 			Method:       http.MethodPut,
 			Function:     postController.UpdatePost,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
 		},
 		{
 			URI:          "/posts/{id}",
 			Method:       http.MethodDelete,
 			Function:     postController.DeletePost,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
 		},
 		{
 			URI:          "/users/{id}/posts",
 			Method:       http.MethodGet,
 			Function:     postController.UserPosts,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
 		},
 		{
 			URI:          "/posts/{id}/like",
 			Method:       http.MethodPost,
 			Function:     postController.LikePost,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
 		},
 		{
 			URI:          "/posts/{id}/unlike",
 			Method:       http.MethodPost,
 			Function:     postController.UnlikePost,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
 		},
 		{
 			URI:          "/posts/{id}/likes",
 			Method:       http.MethodGet,
 			Function:     postController.LikesPost,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
+		},
+		{
+			URI:          "/posts/{id}/repost",
+			Method:       http.MethodPost,
+			Function:     postController.RepostPost,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
+		},
+		{
+			URI:          "/posts/{id}/unrepost",
+			Method:       http.MethodPost,
+			Function:     postController.UnrepostPost,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
+		},
+		{
+			URI:          "/posts/{id}/reposts",
+			Method:       http.MethodGet,
+			Function:     postController.PostReposts,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
 		},
 		{
 			URI:          "/posts/{id}/comments",
 			Method:       http.MethodPost,
 			Function:     postController.NewPost,
 			AuthRequired: true,
+			Scope:        auth.ScopePostsWrite,
+		},
+		{
+			URI:          "/posts/{id}/history",
+			Method:       http.MethodGet,
+			Function:     postController.PostHistory,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
+		},
+		{
+			URI:          "/posts/{id}/thread",
+			Method:       http.MethodGet,
+			Function:     postController.PostThread,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
+		},
+		{
+			URI:          "/posts/{id}/mute",
+			Method:       http.MethodPost,
+			Function:     postController.MuteConversation,
+			AuthRequired: true,
+			Scope:        auth.ScopeNotificationsWrite,
+		},
+		{
+			URI:          "/posts/{id}/unmute",
+			Method:       http.MethodPost,
+			Function:     postController.UnmuteConversation,
+			AuthRequired: true,
+			Scope:        auth.ScopeNotificationsWrite,
 		},
 	}
 }
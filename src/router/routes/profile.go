@@ -3,6 +3,7 @@ package routes
 import (
 	"database/sql"
 	"net/http"
+	"project01/src/auth"
 	"project01/src/controllers"
 )
 
@@ -15,6 +16,14 @@ func profileRoutes(db *sql.DB) []Route {
 			Method:       http.MethodGet,
 			Function:     profileController.GetProfile,
 			AuthRequired: true,
+			Scope:        auth.ScopeUsersRead,
+		},
+		{
+			URI:          "/users/@{username}/profile",
+			Method:       http.MethodGet,
+			Function:     profileController.GetUserProfile,
+			AuthRequired: true,
+			Scope:        auth.ScopeUsersRead,
 		},
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"net/http"
 	"project01/src/middlewares"
+	"project01/src/policy"
+	"project01/src/repositories"
 
 	"github.com/gorilla/mux"
 )
@@ -13,21 +15,52 @@ type Route struct {
 	Method       string
 	Function     func(w http.ResponseWriter, r *http.Request)
 	AuthRequired bool
+	// Scope is the personal access token scope the route requires. Routes
+	// without one can only be called with a session token.
+	Scope string
+	// AllowUnverified exempts an authenticated route from
+	// config.UnverifiedPolicy, e.g. so users can fix a mistyped email.
+	AllowUnverified bool
+	// Permission is the policy action the principal must be allowed to
+	// perform, for routes that are restricted by role.
+	Permission policy.Action
 }
 
 func Load(r *mux.Router, db *sql.DB) *mux.Router {
 	routes := userRoutes(db)
-	routes = append(routes, loginRoutes...)
+	routes = append(routes, loginRoutes(db)...)
+	routes = append(routes, passwordRoutes(db)...)
+	routes = append(routes, twoFactorRoutes(db)...)
+	routes = append(routes, personalAccessTokenRoutes(db)...)
+	routes = append(routes, identityRoutes(db)...)
+	routes = append(routes, wellKnownRoutes...)
 	routes = append(routes, postRoutes(db)...)
+	routes = append(routes, mediaRoutes(db)...)
+	routes = append(routes, tagRoutes(db)...)
 	routes = append(routes, profileRoutes(db)...)
 	routes = append(routes, notificationRoutes(db)...)
+	routes = append(routes, verificationRoutes(db)...)
+	routes = append(routes, adminRoutes(db)...)
+
+	sessionRepo := repositories.NewSessionRepository(db)
+	tokenRepo := repositories.NewPersonalAccessTokenRepository(db)
+	userRepo := repositories.NewUserRepository(db)
 
 	for _, route := range routes {
+		handler := route.Function
+
 		if route.AuthRequired {
-			r.HandleFunc(route.URI, middlewares.AuthMiddleware(route.Function)).Methods(route.Method)
-		} else {
-			r.HandleFunc(route.URI, route.Function).Methods(route.Method)
+			if !route.AllowUnverified {
+				handler = middlewares.VerifiedMiddleware(userRepo, handler)
+			}
+			if route.Permission != "" {
+				handler = middlewares.PermissionMiddleware(route.Permission, handler)
+			}
+			handler = middlewares.ScopeMiddleware(route.Scope, handler)
+			handler = middlewares.AuthMiddleware(sessionRepo, tokenRepo, userRepo, handler)
 		}
+
+		r.HandleFunc(route.URI, handler).Methods(route.Method)
 	}
 
 	return r
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/auth"
+	"project01/src/controllers"
+)
+
+func tagRoutes(db *sql.DB) []Route {
+	tagController := controllers.NewTagController(db)
+
+	return []Route{
+		{
+			URI:          "/tags/{tag}/posts",
+			Method:       http.MethodGet,
+			Function:     tagController.TagPosts,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
+		},
+		{
+			URI:          "/tags/{tag}/follow",
+			Method:       http.MethodPost,
+			Function:     tagController.FollowTag,
+			AuthRequired: true,
+			Scope:        auth.ScopeFollowsWrite,
+		},
+		{
+			URI:          "/tags/{tag}/unfollow",
+			Method:       http.MethodPost,
+			Function:     tagController.UnfollowTag,
+			AuthRequired: true,
+			Scope:        auth.ScopeFollowsWrite,
+		},
+		{
+			URI:          "/tags/following",
+			Method:       http.MethodGet,
+			Function:     tagController.FollowedTags,
+			AuthRequired: true,
+			Scope:        auth.ScopeFollowsRead,
+		},
+		{
+			URI:          "/trends",
+			Method:       http.MethodGet,
+			Function:     tagController.Trends,
+			AuthRequired: true,
+			Scope:        auth.ScopePostsRead,
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/controllers"
+)
+
+func twoFactorRoutes(db *sql.DB) []Route {
+	twoFactorController := controllers.NewTwoFactorController(db)
+
+	return []Route{
+		{
+			URI:          "/2fa/enroll",
+			Method:       http.MethodPost,
+			Function:     twoFactorController.EnrollTwoFactor,
+			AuthRequired: true,
+		},
+		{
+			URI:          "/2fa/confirm",
+			Method:       http.MethodPost,
+			Function:     twoFactorController.ConfirmTwoFactor,
+			AuthRequired: true,
+		},
+		{
+			URI:          "/2fa/disable",
+			Method:       http.MethodPost,
+			Function:     twoFactorController.DisableTwoFactor,
+			AuthRequired: true,
+		},
+	}
+}
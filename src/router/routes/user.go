@@ -3,6 +3,7 @@ package routes
 import (
 	"database/sql"
 	"net/http"
+	"project01/src/auth"
 	"project01/src/controllers"
 )
 
@@ -21,48 +22,115 @@ func userRoutes(db *sql.DB) []Route {
 			Method:       http.MethodGet,
 			Function:     userController.FindByFilters,
 			AuthRequired: true,
+			Scope:        auth.ScopeUsersRead,
+		},
+		{
+			// Registered before /users/{id} so that it is not taken for an ID.
+			URI:          "/users/autocomplete",
+			Method:       http.MethodGet,
+			Function:     userController.AutocompleteUsers,
+			AuthRequired: true,
+			Scope:        auth.ScopeUsersRead,
 		},
 		{
 			URI:          "/users/{id}",
 			Method:       http.MethodGet,
 			Function:     userController.FindUser,
 			AuthRequired: true,
+			Scope:        auth.ScopeUsersRead,
 		},
 		{
-			URI:          "/users/{id}",
+			URI:             "/users/{id}",
+			Method:          http.MethodPut,
+			Function:        userController.UpdateUser,
+			AuthRequired:    true,
+			Scope:           auth.ScopeUsersWrite,
+			AllowUnverified: true,
+		},
+		{
+			URI:             "/users/{id}",
+			Method:          http.MethodPatch,
+			Function:        userController.PatchUser,
+			AuthRequired:    true,
+			Scope:           auth.ScopeUsersWrite,
+			AllowUnverified: true,
+		},
+		{
+			URI:             "/users/{id}",
+			Method:          http.MethodDelete,
+			Function:        userController.DeleteUser,
+			AuthRequired:    true,
+			AllowUnverified: true,
+		},
+		{
+			URI:          "/users/{id}/avatar",
 			Method:       http.MethodPut,
-			Function:     userController.UpdateUser,
+			Function:     userController.UploadAvatar,
 			AuthRequired: true,
+			Scope:        auth.ScopeUsersWrite,
 		},
 		{
-			URI:          "/users/{id}",
+			URI:          "/users/{id}/avatar",
+			Method:       http.MethodDelete,
+			Function:     userController.DeleteAvatar,
+			AuthRequired: true,
+			Scope:        auth.ScopeUsersWrite,
+		},
+		{
+			URI:          "/users/{id}/banner",
+			Method:       http.MethodPut,
+			Function:     userController.UploadBanner,
+			AuthRequired: true,
+			Scope:        auth.ScopeUsersWrite,
+		},
+		{
+			URI:          "/users/{id}/banner",
 			Method:       http.MethodDelete,
-			Function:     userController.DeleteUser,
+			Function:     userController.DeleteBanner,
 			AuthRequired: true,
+			Scope:        auth.ScopeUsersWrite,
 		},
 		{
 			URI:          "/users/{id}/follow",
 			Method:       http.MethodPost,
 			Function:     userController.FollowUser,
 			AuthRequired: true,
+			Scope:        auth.ScopeFollowsWrite,
 		},
 		{
 			URI:          "/users/{id}/unfollow",
 			Method:       http.MethodPost,
 			Function:     userController.UnfollowUser,
 			AuthRequired: true,
+			Scope:        auth.ScopeFollowsWrite,
+		},
+		{
+			URI:          "/users/{id}/block",
+			Method:       http.MethodPost,
+			Function:     userController.BlockUser,
+			AuthRequired: true,
+			Scope:        auth.ScopeFollowsWrite,
+		},
+		{
+			URI:          "/users/{id}/unblock",
+			Method:       http.MethodPost,
+			Function:     userController.UnblockUser,
+			AuthRequired: true,
+			Scope:        auth.ScopeFollowsWrite,
 		},
 		{
 			URI:          "/users/{id}/followers",
 			Method:       http.MethodGet,
 			Function:     userController.UserFollowers,
 			AuthRequired: true,
+			Scope:        auth.ScopeFollowsRead,
 		},
 		{
 			URI:          "/users/{id}/following",
 			Method:       http.MethodGet,
 			Function:     userController.UserFollowing,
 			AuthRequired: true,
+			Scope:        auth.ScopeFollowsRead,
 		},
 	}
 }
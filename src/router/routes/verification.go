@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+	"project01/src/controllers"
+)
+
+func verificationRoutes(db *sql.DB) []Route {
+	verificationController := controllers.NewVerificationController(db)
+
+	return []Route{
+		{
+			URI:          "/verify-email",
+			Method:       http.MethodGet,
+			Function:     verificationController.VerifyEmail,
+			AuthRequired: false,
+		},
+		{
+			URI:             "/verify-email/resend",
+			Method:          http.MethodPost,
+			Function:        verificationController.ResendVerification,
+			AuthRequired:    true,
+			AllowUnverified: true,
+		},
+	}
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"net/http"
+	"project01/src/controllers"
+)
+
+var wellKnownRoutes = []Route{
+	{
+		URI:          "/.well-known/jwks.json",
+		Method:       http.MethodGet,
+		Function:     controllers.JWKS,
+		AuthRequired: false,
+	},
+}
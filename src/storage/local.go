@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalPathPrefix is where the router serves files kept by LocalStorage.
+const LocalPathPrefix = "/files/"
+
+// LocalStorage keeps files in a directory on disk.
+type LocalStorage struct {
+	Dir       string
+	PublicURL string
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key, contentType string, body []byte) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	path := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see half a file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	err := filepath.WalkDir(s.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == s.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, Object{Key: key, Size: info.Size(), ModifiedAt: info.ModTime()})
+		return nil
+	})
+
+	return objects, err
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return strings.TrimSuffix(s.PublicURL, "/") + "/" + key
+}
+
+// Handler serves the stored files. Directory listings are not served.
+func (s *LocalStorage) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.Dir))
+
+	return http.StripPrefix(LocalPathPrefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	}))
+}
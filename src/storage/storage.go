@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"project01/src/config"
+	"strings"
+	"time"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Object is a stored file.
+type Object struct {
+	Key        string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// Storage keeps uploaded files. Keys are slash-separated paths such as
+// "avatars/1/3f2a.jpg".
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, body []byte) error
+	Delete(ctx context.Context, key string) error
+	// List returns every object whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// URL is where clients can download the object.
+	URL(key string) string
+}
+
+// New returns the storage selected by config.StorageDriver. Anything other
+// than "s3" falls back to the local filesystem, which is meant for local
+// development.
+func New() Storage {
+	if config.StorageDriver == "s3" {
+		publicURL := config.StoragePublicURL
+		if publicURL == "" {
+			publicURL = strings.TrimSuffix(config.S3Endpoint, "/") + "/" + config.S3Bucket
+		}
+
+		return &S3Storage{
+			Endpoint:        config.S3Endpoint,
+			Region:          config.S3Region,
+			Bucket:          config.S3Bucket,
+			AccessKeyID:     config.S3AccessKeyID,
+			SecretAccessKey: config.S3SecretAccessKey,
+			PublicURL:       publicURL,
+		}
+	}
+
+	publicURL := config.StoragePublicURL
+	if publicURL == "" {
+		publicURL = config.AppURL + LocalPathPrefix
+	}
+
+	return &LocalStorage{Dir: config.StorageLocalDir, PublicURL: publicURL}
+}
+
+// validKey rejects keys that are empty, absolute or could escape the storage
+// root.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps counters in process memory. It is only suitable for a
+// single instance.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+}
+
+type memoryCounter struct {
+	Counter
+	lastFailure time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*memoryCounter)}
+}
+
+func (s *MemoryStore) Get(key string) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if counter, ok := s.counters[key]; ok {
+		return counter.Counter, nil
+	}
+	return Counter{}, nil
+}
+
+func (s *MemoryStore) Increment(key string, window time.Duration) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	counter, ok := s.counters[key]
+	if !ok {
+		counter = &memoryCounter{}
+		s.counters[key] = counter
+	}
+
+	if now.Sub(counter.lastFailure) > window {
+		counter.Failures = 0
+	}
+
+	counter.Failures++
+	counter.lastFailure = now
+
+	s.collect(now, window)
+
+	return counter.Counter, nil
+}
+
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if counter, ok := s.counters[key]; ok {
+		counter.LockedUntil = until
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+	return nil
+}
+
+// collect drops counters that can no longer affect a login so the map
+// doesn't grow without bound. It must be called with mu held.
+func (s *MemoryStore) collect(now time.Time, window time.Duration) {
+	for key, counter := range s.counters {
+		if now.Sub(counter.lastFailure) > window && now.After(counter.LockedUntil) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package throttle
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PostgresStore keeps counters in the login_throttles table so that every
+// instance sees the same failures.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+func (s *PostgresStore) Get(key string) (Counter, error) {
+	var counter Counter
+	var lockedUntil sql.NullTime
+
+	query := `SELECT failures, locked_until FROM login_throttles WHERE key = $1`
+	err := s.DB.QueryRow(query, key).Scan(&counter.Failures, &lockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Counter{}, nil
+		}
+		return Counter{}, err
+	}
+
+	counter.LockedUntil = lockedUntil.Time
+	return counter, nil
+}
+
+func (s *PostgresStore) Increment(key string, window time.Duration) (Counter, error) {
+	var counter Counter
+	var lockedUntil sql.NullTime
+
+	query := `INSERT INTO login_throttles (key, failures, last_failure_at) VALUES ($1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure_at < CURRENT_TIMESTAMP - make_interval(secs => $2) THEN 1
+				ELSE login_throttles.failures + 1 END,
+			last_failure_at = CURRENT_TIMESTAMP
+		RETURNING failures, locked_until`
+	err := s.DB.QueryRow(query, key, window.Seconds()).Scan(&counter.Failures, &lockedUntil)
+	if err != nil {
+		return Counter{}, err
+	}
+
+	counter.LockedUntil = lockedUntil.Time
+	return counter, nil
+}
+
+func (s *PostgresStore) Lock(key string, until time.Time) error {
+	_, err := s.DB.Exec(`UPDATE login_throttles SET locked_until = $1 WHERE key = $2`, until, key)
+	return err
+}
+
+func (s *PostgresStore) Reset(key string) error {
+	_, err := s.DB.Exec(`DELETE FROM login_throttles WHERE key = $1`, key)
+	return err
+}
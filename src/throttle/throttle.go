@@ -0,0 +1,97 @@
+package throttle
+
+import (
+	"database/sql"
+	"project01/src/config"
+	"time"
+)
+
+// Counter is the failure state tracked for a single key.
+type Counter struct {
+	Failures    int
+	LockedUntil time.Time
+}
+
+// Store keeps failure counters. Implementations must be safe for concurrent
+// use; the Postgres store is shared by every instance of the API.
+type Store interface {
+	Get(key string) (Counter, error)
+	// Increment records a failure. The count starts over when the previous
+	// failure is older than window.
+	Increment(key string, window time.Duration) (Counter, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// NewStore returns the store selected by config.ThrottleStore.
+func NewStore(db *sql.DB) Store {
+	if config.ThrottleStore == "memory" {
+		return NewMemoryStore()
+	}
+
+	return NewPostgresStore(db)
+}
+
+// Limiter locks a key out once it reaches Threshold failures within Window.
+// Each further failure doubles the lockout, up to MaxDelay.
+type Limiter struct {
+	Store     Store
+	Prefix    string
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// Check returns how long id must wait before trying again, or zero if it
+// isn't locked out.
+func (l *Limiter) Check(id string) (time.Duration, error) {
+	counter, err := l.Store.Get(l.Prefix + id)
+	if err != nil {
+		return 0, err
+	}
+
+	return retryAfter(counter.LockedUntil), nil
+}
+
+// Fail records a failure for id and returns the resulting lockout, if any.
+func (l *Limiter) Fail(id string) (time.Duration, error) {
+	key := l.Prefix + id
+
+	counter, err := l.Store.Increment(key, l.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	if counter.Failures < l.Threshold {
+		return 0, nil
+	}
+
+	delay := l.BaseDelay
+	for i := l.Threshold; i < counter.Failures && delay < l.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.MaxDelay {
+		delay = l.MaxDelay
+	}
+
+	lockedUntil := time.Now().Add(delay)
+	if err = l.Store.Lock(key, lockedUntil); err != nil {
+		return 0, err
+	}
+
+	return delay, nil
+}
+
+// Reset clears the failures of id, e.g. after a successful login.
+func (l *Limiter) Reset(id string) error {
+	return l.Store.Reset(l.Prefix + id)
+}
+
+func retryAfter(lockedUntil time.Time) time.Duration {
+	wait := time.Until(lockedUntil)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
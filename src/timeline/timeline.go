@@ -0,0 +1,125 @@
+package timeline
+
+import (
+	"database/sql"
+	"log"
+	"project01/src/config"
+	"project01/src/repositories"
+	"time"
+)
+
+type jobKind int
+
+const (
+	postCreated jobKind = iota
+	reposted
+	followed
+	unfollowed
+)
+
+type job struct {
+	kind jobKind
+	// userID is the follower for follow jobs, the author for posts and the
+	// reposter for reposts.
+	userID   uint64
+	authorID uint64
+	postID   uint64
+	repostID uint64
+}
+
+// queues holds one queue per worker. Jobs are routed by user so that the
+// follow and unfollow jobs of a user run in order.
+var queues []chan job
+
+var repo repositories.TimelineRepositoryInterface
+
+// Start starts the workers that keep timelines up to date. Until it is
+// called, timeline updates are dropped.
+func Start(db *sql.DB) {
+	repo = repositories.NewTimelineRepository(db)
+
+	workers := max(config.TimelineWorkers, 1)
+	queues = make([]chan job, workers)
+	for i := range queues {
+		queues[i] = make(chan job, config.TimelineQueueSize)
+		go work(queues[i])
+	}
+}
+
+// PostCreated fans a new post out to the timelines of its author's followers.
+func PostCreated(postID, authorID uint64) {
+	enqueue(job{kind: postCreated, userID: authorID, authorID: authorID, postID: postID})
+}
+
+// Reposted fans a repost out to the timelines of the reposter's followers.
+func Reposted(repostID, reposterID uint64) {
+	enqueue(job{kind: reposted, userID: reposterID, repostID: repostID})
+}
+
+// Followed adds the recent posts of authorID to the timeline of userID.
+func Followed(userID, authorID uint64) {
+	enqueue(job{kind: followed, userID: userID, authorID: authorID})
+}
+
+// Unfollowed removes the posts of authorID from the timeline of userID.
+func Unfollowed(userID, authorID uint64) {
+	enqueue(job{kind: unfollowed, userID: userID, authorID: authorID})
+}
+
+// Rebuild rebuilds the timeline of userID from the authors they follow. It
+// runs synchronously and is meant for maintenance.
+func Rebuild(db *sql.DB, userID uint64) error {
+	return repositories.NewTimelineRepository(db).Rebuild(userID, config.TimelineBackfillLimit)
+}
+
+func enqueue(j job) {
+	if len(queues) == 0 {
+		log.Println("timeline: workers not started, dropping update")
+		return
+	}
+
+	queue := queues[j.userID%uint64(len(queues))]
+	select {
+	case queue <- j:
+		return
+	default:
+	}
+
+	// Running the job outside its worker would let it overtake earlier jobs
+	// of the same user, e.g. a backfill after the unfollow that follows it.
+	// Wait briefly for room instead, and drop the update if there is none;
+	// `timeline rebuild` repairs a timeline that missed one.
+	timer := time.NewTimer(config.TimelineEnqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case queue <- j:
+	case <-timer.C:
+		log.Printf("timeline: queue full, dropping update of user %d", j.userID)
+	}
+}
+
+func work(queue chan job) {
+	for j := range queue {
+		run(j)
+	}
+}
+
+func run(j job) {
+	var err error
+
+	switch j.kind {
+	case postCreated:
+		err = repo.FanOut(j.postID, j.authorID, config.TimelineFanOutLimit)
+	case reposted:
+		err = repo.FanOutRepost(j.repostID, j.userID, config.TimelineFanOutLimit)
+	case followed:
+		err = repo.Backfill(j.userID, j.authorID, config.TimelineBackfillLimit)
+	case unfollowed:
+		err = repo.RemoveAuthor(j.userID, j.authorID)
+	}
+
+	if err != nil {
+		log.Printf("timeline: %v", err)
+	}
+}
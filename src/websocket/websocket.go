@@ -20,17 +20,18 @@ var upgrader = websocket.Upgrader{
 
 // HandleConnections handles websocket connections
 func HandleConnections(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("error: %v", err)
+		return
 	}
-	defer ws.Close()
+	userID := principal.UserID
 
-	userID, err := auth.ExtractUserID(r)
+	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("error: %v", err)
-		return
+		log.Fatal(err)
 	}
+	defer ws.Close()
 
 	if _, ok := userChannels[userID]; !ok {
 		userChannels[userID] = make(chan models.Notification)